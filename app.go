@@ -37,6 +37,50 @@ func (a *App) startup(ctx context.Context) {
 	a.Audio = audio.NewAudio()
 	a.Notes = *notes.NewNotes(ctx, configHelper)
 	a.Helpers = fronthelpers.NewHelpers(ctx, configHelper, &a.Whisper, &a.Notes)
+
+	if err := a.Notes.MigrateMetadata(); err != nil {
+		fmt.Printf("Error while migrating note metadata: %s\n", err)
+	}
+
+	go func() {
+		if err := a.Notes.SyncIndex(); err != nil {
+			fmt.Printf("Error while updating search index: %s\n", err)
+		}
+	}()
+
+	if err := a.Notes.SetupHistory(); err != nil {
+		fmt.Printf("Error while setting up notes history: %s\n", err)
+	}
+
+	if err := a.Notes.Watch(); err != nil {
+		fmt.Printf("Error while watching notes: %s\n", err)
+	}
+
+	purged, err := a.Notes.PurgeTrash()
+	if err != nil {
+		fmt.Printf("Error while purging trash: %s\n", err)
+	}
+
+	if len(purged) > 0 {
+		fmt.Println("Purged trashed notes:", purged)
+	}
+
+	if days := configHelper.GetConfig().UnusedModelDays; days > 0 {
+		removed, err := a.Whisper.CleanupUnusedModels(days)
+		if err != nil {
+			fmt.Printf("Error while removing unused models: %s\n", err)
+		}
+
+		if len(removed) > 0 {
+			fmt.Println("Removed unused models:", removed)
+		}
+	}
+}
+
+func (a *App) shutdown(ctx context.Context) {
+	if err := notes.FlushSearchIndex(); err != nil {
+		fmt.Printf("Error while saving search index: %s\n", err)
+	}
 }
 
 func (a *App) Echo(str string) string {
@@ -15,9 +15,86 @@ type Config struct {
 	ModelPath    string `mapstructure:"ModelPath"`
 	NotesPath    string `mapstructure:"NotesPath"`
 	CurrentModel string `mapstructure:"CurrentModel"`
+	// How notes are stored in NotesPath: "folders" or "vault", change it
+	// with Notes.ConvertLayout so the files are moved too
+	NotesLayout string `mapstructure:"NotesLayout"`
+	// Unfinished long transcriptions are kept here to be resumed
+	CheckpointPath string `mapstructure:"CheckpointPath"`
+	// Search index of the notes, can be removed to rebuild it
+	IndexPath string `mapstructure:"IndexPath"`
 
 	MicrophoneId     string `mapstructure:"MicrophoneId"`
 	PreferedLanguage string `mapstructure:"PreferedLanguage"`
+	// With "auto" language, detect it for every chunk instead of the whole recording
+	LanguagePerChunk     bool `mapstructure:"LanguagePerChunk"`
+	LanguageChunkSeconds int  `mapstructure:"LanguageChunkSeconds"`
+	// Words whisper is less sure about than this are highlighted for review
+	LowConfidenceThreshold float64 `mapstructure:"LowConfidenceThreshold"`
+
+	// What to do with hallucinated segments: "off", "flag" or "remove"
+	HallucinationFilter string `mapstructure:"HallucinationFilter"`
+
+	// Stages of the clean transcript text, verbatim one is always kept
+	RemoveFillers    bool `mapstructure:"RemoveFillers"`
+	SplitParagraphs  bool `mapstructure:"SplitParagraphs"`
+	ParagraphPauseMs int  `mapstructure:"ParagraphPauseMs"`
+	FixCasing        bool `mapstructure:"FixCasing"`
+	NormalizeNumbers bool `mapstructure:"NormalizeNumbers"`
+
+	// Recordings longer than this are transcribed in chunks with checkpoints
+	LongAudioSeconds        int `mapstructure:"LongAudioSeconds"`
+	LongAudioChunkSeconds   int `mapstructure:"LongAudioChunkSeconds"`
+	LongAudioOverlapSeconds int `mapstructure:"LongAudioOverlapSeconds"`
+
+	// Models that weren't used for this amount of days are removed on startup, 0 disables it
+	UnusedModelDays int `mapstructure:"UnusedModelDays"`
+	// Trashed notes are deleted for good after this amount of days, 0 keeps them
+	TrashRetentionDays int `mapstructure:"TrashRetentionDays"`
+
+	// Keep NotesPath as a git repository and commit changes of notes
+	GitHistory bool `mapstructure:"GitHistory"`
+	// Changes made within this many seconds go into a single commit
+	GitCommitDelaySeconds int `mapstructure:"GitCommitDelaySeconds"`
+	// Recordings are big and don't change, they can be left out of the history
+	GitExcludeAudio bool `mapstructure:"GitExcludeAudio"`
+
+	MaxConcurrentDownloads int `mapstructure:"MaxConcurrentDownloads"`
+	// Local registry overriding the embedded one, defaults to registry.json in ModelPath
+	ModelRegistryPath string `mapstructure:"ModelRegistryPath"`
+
+	// Empty ProxyUrl falls back to HTTP_PROXY/HTTPS_PROXY env variables
+	ProxyUrl      string   `mapstructure:"ProxyUrl"`
+	ProxyUsername string   `mapstructure:"ProxyUsername"`
+	ProxyPassword string   `mapstructure:"ProxyPassword"`
+	CaBundles     []string `mapstructure:"CaBundles"`
+	// Seconds to wait for connection and response headers, 0 disables it
+	RequestTimeout int `mapstructure:"RequestTimeout"`
+	// KB/s, 0 means unlimited
+	BandwidthLimit int `mapstructure:"BandwidthLimit"`
+
+	// "local" runs whisper.cpp, "remote" sends audio to RemoteUrl
+	Engine string `mapstructure:"Engine"`
+	// Base url of an OpenAI-compatible server, e.g. http://gpu-box:8000
+	RemoteUrl    string `mapstructure:"RemoteUrl"`
+	RemoteApiKey string `mapstructure:"RemoteApiKey"`
+	RemoteModel  string `mapstructure:"RemoteModel"`
+	// Seconds for the whole remote transcription, 0 disables it
+	RemoteTimeout int `mapstructure:"RemoteTimeout"`
+
+	Profiles []Profile `mapstructure:"Profiles"`
+	// Profile used when a recording doesn't pick one, empty uses the keys above
+	DefaultProfile string `mapstructure:"DefaultProfile"`
+}
+
+// Named engine settings picked for a recording, e.g. a quick local one and
+// one sending audio to a GPU box. Empty fields fall back to the keys of Config
+type Profile struct {
+	Name string `mapstructure:"Name"`
+	// "local" or "remote"
+	Engine    string `mapstructure:"Engine"`
+	Model     string `mapstructure:"Model"`
+	Language  string `mapstructure:"Language"`
+	RemoteUrl string `mapstructure:"RemoteUrl"`
 }
 
 type ConfigHelper struct {
@@ -32,19 +109,60 @@ func init() {
 	ModelPath := ""
 	defaultModel := "large-v3-turbo"
 	notesPath := ""
+	checkpointPath := ""
+	indexPath := ""
 
 	switch runtime.GOOS {
 	case "windows":
 		ModelPath = os.Getenv("AppData") + "\\" + appname + "\\models"
 		notesPath = os.Getenv("AppData") + "\\" + appname + "\\notes"
+		checkpointPath = os.Getenv("AppData") + "\\" + appname + "\\checkpoints"
+		indexPath = os.Getenv("AppData") + "\\" + appname + "\\index"
 	case "darwin", "linux":
 		ModelPath = "$HOME/.config/" + appname + "/models"
 		notesPath = "$HOME/.config/" + appname + "/notes"
+		checkpointPath = "$HOME/.config/" + appname + "/checkpoints"
+		indexPath = "$HOME/.config/" + appname + "/index"
 	}
 
 	viper.Set("ModelPath", ModelPath)
 	viper.Set("CurrentModel", defaultModel)
 	viper.Set("PreferedLanguage", "en")
+	viper.SetDefault("LanguagePerChunk", false)
+	viper.SetDefault("LanguageChunkSeconds", 30)
+	viper.SetDefault("LowConfidenceThreshold", 0.5)
+	viper.SetDefault("HallucinationFilter", "flag")
+	viper.SetDefault("RemoveFillers", false)
+	viper.SetDefault("SplitParagraphs", false)
+	viper.SetDefault("ParagraphPauseMs", 2000)
+	viper.SetDefault("FixCasing", false)
+	viper.SetDefault("NormalizeNumbers", false)
+	viper.SetDefault("NotesLayout", "folders")
+	viper.SetDefault("CheckpointPath", checkpointPath)
+	viper.SetDefault("IndexPath", indexPath)
+	viper.SetDefault("LongAudioSeconds", 10*60)
+	viper.SetDefault("LongAudioChunkSeconds", 2*60)
+	viper.SetDefault("LongAudioOverlapSeconds", 2)
+	viper.SetDefault("UnusedModelDays", 0)
+	viper.SetDefault("TrashRetentionDays", 30)
+	viper.SetDefault("GitHistory", false)
+	viper.SetDefault("GitCommitDelaySeconds", 10)
+	viper.SetDefault("GitExcludeAudio", true)
+	viper.SetDefault("MaxConcurrentDownloads", 2)
+	viper.SetDefault("ModelRegistryPath", "")
+	viper.SetDefault("ProxyUrl", "")
+	viper.SetDefault("ProxyUsername", "")
+	viper.SetDefault("ProxyPassword", "")
+	viper.SetDefault("CaBundles", []string{})
+	viper.SetDefault("RequestTimeout", 30)
+	viper.SetDefault("BandwidthLimit", 0)
+	viper.SetDefault("Engine", "local")
+	viper.SetDefault("RemoteUrl", "")
+	viper.SetDefault("RemoteApiKey", "")
+	viper.SetDefault("RemoteModel", "")
+	viper.SetDefault("RemoteTimeout", 0)
+	viper.SetDefault("Profiles", []Profile{})
+	viper.SetDefault("DefaultProfile", "")
 
 	// TODO: instead of default, always ask user first
 	viper.Set("NotesPath", notesPath)
@@ -82,10 +200,32 @@ func (c ConfigHelper) GetConfig() *Config {
 
 	cfg.ModelPath = os.ExpandEnv(cfg.ModelPath)
 	cfg.NotesPath = os.ExpandEnv(cfg.NotesPath)
+	cfg.CheckpointPath = os.ExpandEnv(cfg.CheckpointPath)
+	cfg.IndexPath = os.ExpandEnv(cfg.IndexPath)
 
 	return &cfg
 }
 
+// Profile by its name, DefaultProfile for an empty one. Without any, the
+// profile is empty and only the keys of Config are used
+func (c *Config) FindProfile(name string) (Profile, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+
+	if name == "" {
+		return Profile{}, nil
+	}
+
+	for _, profile := range c.Profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+
+	return Profile{}, fmt.Errorf("Profile %s doesn't exist", name)
+}
+
 func (c ConfigHelper) UpdateConfig(field string, value any) error {
 	viper.Set(field, value)
 
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// Values of the Engine config key
+const (
+	Local  = "local"
+	Remote = "remote"
+)
+
+// Turns audio into text. Data is mono PCM at wav.SampleRate
+type Engine interface {
+	Process(modelname string, data []float32, lang string, processCallback func(int)) (*transcript.Transcript, error)
+}
+
+// Engine that splits long recordings into chunks and checkpoints every
+// finished one, so transcription can be resumed after a crash
+type LongEngine interface {
+	Engine
+	ProcessLong(modelname string, data []float32, lang string, processCallback func(int)) (*transcript.Transcript, string, error)
+	ResumeLongJob(id string, processCallback func(int)) (*transcript.Transcript, error)
+	LongJobAudio(id string) ([]float32, error)
+	DeleteLongJob(id string) error
+}
+
+// Engine that can detect language of every chunk separately
+type MixedLanguageEngine interface {
+	Engine
+	ProcessMixedLanguages(modelname string, data []float32, chunkSeconds int, processCallback func(int)) (*transcript.Transcript, error)
+}
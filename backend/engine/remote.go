@@ -0,0 +1,325 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/config"
+	"github.com/henmalib/whisper-notes/backend/network"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+	"github.com/henmalib/whisper-notes/backend/wav"
+)
+
+const (
+	transcriptionsPath = "/v1/audio/transcriptions"
+	defaultRemoteModel = "whisper-1"
+	// Part of the progress bar taken by the upload, the rest is waiting for the server
+	uploadShare = 90
+)
+
+// Sends audio to an OpenAI-compatible transcription server, like the one
+// bundled with whisper.cpp or faster-whisper-server
+type RemoteEngine struct {
+	cfg config.ConfigLoader
+}
+
+func NewRemote(cfg config.ConfigLoader) *RemoteEngine {
+	return &RemoteEngine{cfg}
+}
+
+type remoteWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// OpenAI doesn't return it, whisper.cpp and faster-whisper do
+	Probability *float32 `json:"probability"`
+}
+
+type remoteSegment struct {
+	Start float64      `json:"start"`
+	End   float64      `json:"end"`
+	Text  string       `json:"text"`
+	Words []remoteWord `json:"words"`
+}
+
+// verbose_json response format
+type remoteResponse struct {
+	Text     string          `json:"text"`
+	Language string          `json:"language"`
+	Segments []remoteSegment `json:"segments"`
+	// OpenAI returns words separately from segments
+	Words []remoteWord `json:"words"`
+}
+
+type remoteError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Reports how much of the request body was sent
+type progressReader struct {
+	r        io.Reader
+	read     int
+	total    int
+	callback func(int)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += n
+
+	if p.callback != nil && p.total > 0 {
+		p.callback(p.read * uploadShare / p.total)
+	}
+
+	return n, err
+}
+
+func (e *RemoteEngine) endpoint(cfg *config.Config) (string, error) {
+	if cfg.RemoteUrl == "" {
+		return "", errors.New("Remote engine url is not set")
+	}
+
+	base, err := url.Parse(cfg.RemoteUrl)
+	if err != nil {
+		return "", fmt.Errorf("Invalid remote engine url: %w", err)
+	}
+
+	return base.JoinPath(transcriptionsPath).String(), nil
+}
+
+func (e *RemoteEngine) client(cfg *config.Config) (*http.Client, error) {
+	client, err := network.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Server answers only when transcription is done, which takes way longer
+	// than RequestTimeout. RemoteTimeout limits the whole request instead
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.ResponseHeaderTimeout = 0
+	}
+
+	if cfg.RemoteTimeout > 0 {
+		client.Timeout = time.Duration(cfg.RemoteTimeout) * time.Second
+	}
+
+	return client, nil
+}
+
+func writeRequestBody(data []float32, modelname, lang string) (*bytes.Buffer, string, error) {
+	audio, err := wav.Encode(data, wav.SampleRate)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+
+	file, err := form.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := file.Write(audio); err != nil {
+		return nil, "", err
+	}
+
+	fields := [][2]string{
+		{"model", modelname},
+		{"response_format", "verbose_json"},
+		{"temperature", "0"},
+		{"timestamp_granularities[]", "segment"},
+		{"timestamp_granularities[]", "word"},
+	}
+
+	// Leaving language out makes the server detect it
+	if lang != "" && lang != "auto" {
+		fields = append(fields, [2]string{"language", lang})
+	}
+
+	for _, field := range fields {
+		if err := form.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body, form.FormDataContentType(), nil
+}
+
+// Empty modelname falls back to RemoteModel from the config
+func (e *RemoteEngine) Process(modelname string, data []float32, lang string, processCallback func(int)) (*transcript.Transcript, error) {
+	if len(data) == 0 {
+		return nil, errors.New("No audio data provided")
+	}
+
+	cfg := e.cfg.GetConfig()
+
+	if modelname == "" {
+		modelname = cfg.RemoteModel
+	}
+	if modelname == "" {
+		modelname = defaultRemoteModel
+	}
+
+	endpoint, err := e.endpoint(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := e.client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	body, contentType, err := writeRequestBody(data, modelname, lang)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't prepare the request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, &progressReader{
+		r:        body,
+		total:    body.Len(),
+		callback: processCallback,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", contentType)
+	if cfg.RemoteApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.RemoteApiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't reach the remote engine: %w", err)
+	}
+	defer resp.Body.Close()
+
+	responseBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read the remote engine response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var remoteErr remoteError
+		if json.Unmarshal(responseBytes, &remoteErr) == nil && remoteErr.Error.Message != "" {
+			return nil, fmt.Errorf("Remote engine returned %s: %s", resp.Status, remoteErr.Error.Message)
+		}
+
+		return nil, fmt.Errorf("Remote engine returned %s", resp.Status)
+	}
+
+	var response remoteResponse
+	if err := json.Unmarshal(responseBytes, &response); err != nil {
+		return nil, fmt.Errorf("Invalid remote engine response: %w", err)
+	}
+
+	if processCallback != nil {
+		processCallback(100)
+	}
+
+	result := response.transcript()
+	result.Model = modelname
+	if lang != "" && lang != "auto" {
+		result.Language = transcript.LanguageCode(lang)
+	}
+
+	return result, nil
+}
+
+func secondsToMs(seconds float64) int64 {
+	return int64(seconds * 1000)
+}
+
+func (w remoteWord) word() transcript.Word {
+	// Without probabilities every word is treated as certain, so nothing
+	// gets highlighted as low confidence
+	probability := float32(1)
+	if w.Probability != nil {
+		probability = *w.Probability
+	}
+
+	return transcript.Word{
+		Text:        strings.TrimSpace(w.Word),
+		Start:       secondsToMs(w.Start),
+		End:         secondsToMs(w.End),
+		Probability: probability,
+	}
+}
+
+func (r *remoteResponse) transcript() *transcript.Transcript {
+	// OpenAI returns full language names, whisper.cpp server returns codes
+	result := &transcript.Transcript{
+		Text:     strings.TrimSpace(r.Text),
+		Language: transcript.LanguageCode(r.Language),
+	}
+
+	next := 0
+	for _, s := range r.Segments {
+		segment := transcript.Segment{
+			Start: secondsToMs(s.Start),
+			End:   secondsToMs(s.End),
+			Text:  strings.TrimSpace(s.Text),
+		}
+
+		for _, w := range s.Words {
+			segment.Words = append(segment.Words, w.word())
+		}
+
+		// Top level words belong to the segment they start in
+		for ; next < len(r.Words) && secondsToMs(r.Words[next].Start) < segment.End; next++ {
+			segment.Words = append(segment.Words, r.Words[next].word())
+		}
+
+		result.Segments = append(result.Segments, segment)
+	}
+
+	// Timings of words and segments don't always agree, words after the end
+	// of the last segment still belong to it
+	if next < len(r.Words) {
+		if len(result.Segments) == 0 {
+			texts := []string{}
+			for _, w := range r.Words[next:] {
+				texts = append(texts, strings.TrimSpace(w.Word))
+			}
+
+			result.Segments = append(result.Segments, transcript.Segment{
+				Start: secondsToMs(r.Words[next].Start),
+				Text:  strings.Join(texts, " "),
+			})
+		}
+
+		last := &result.Segments[len(result.Segments)-1]
+		for ; next < len(r.Words); next++ {
+			word := r.Words[next].word()
+			last.Words = append(last.Words, word)
+			last.End = max(last.End, word.End)
+		}
+	}
+
+	if result.Text == "" {
+		texts := []string{}
+		for _, segment := range result.Segments {
+			texts = append(texts, segment.Text)
+		}
+		result.Text = strings.Join(texts, " ")
+	}
+
+	return result
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/config"
+	"github.com/henmalib/whisper-notes/backend/wav"
+)
+
+type stubConfig struct {
+	cfg config.Config
+}
+
+func (s stubConfig) GetConfig() *config.Config {
+	return &s.cfg
+}
+
+func newStubEngine(t *testing.T, handler http.HandlerFunc) (*RemoteEngine, *config.Config) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	stub := &stubConfig{cfg: config.Config{RemoteUrl: server.URL, RemoteApiKey: "secret"}}
+	return NewRemote(stub), &stub.cfg
+}
+
+// A second of silence
+var stubAudio = make([]float32, wav.SampleRate)
+
+const verboseResponse = `{
+	"text": " Hello there. General Kenobi.",
+	"language": "english",
+	"segments": [
+		{"start": 0, "end": 1.2, "text": " Hello there."},
+		{"start": 1.2, "end": 2.0, "text": " General Kenobi."}
+	],
+	"words": [
+		{"word": "Hello", "start": 0.1, "end": 0.5},
+		{"word": "there.", "start": 0.6, "end": 1.1},
+		{"word": "General", "start": 1.3, "end": 1.8},
+		{"word": "Kenobi.", "start": 2.05, "end": 2.4}
+	]
+}`
+
+func TestRemoteProcess(t *testing.T) {
+	engine, _ := newStubEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != transcriptionsPath {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Missing api key, got %q", r.Header.Get("Authorization"))
+		}
+
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := r.FormValue("response_format"); got != "verbose_json" {
+			t.Errorf("Expected verbose_json, got %q", got)
+		}
+
+		if got := r.FormValue("language"); got != "" {
+			t.Errorf("Language should be detected by the server, got %q", got)
+		}
+
+		if _, _, err := r.FormFile("file"); err != nil {
+			t.Errorf("Missing audio file: %v", err)
+		}
+
+		w.Write([]byte(verboseResponse))
+	})
+
+	progress := 0
+	result, err := engine.Process("", stubAudio, "auto", func(p int) { progress = p })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if progress != 100 {
+		t.Errorf("Expected progress to end at 100, got %d", progress)
+	}
+
+	if result.Model != defaultRemoteModel {
+		t.Errorf("Expected model %s, got %s", defaultRemoteModel, result.Model)
+	}
+
+	if result.Language != "en" {
+		t.Errorf("Expected language en, got %q", result.Language)
+	}
+
+	if result.Text != "Hello there. General Kenobi." {
+		t.Errorf("Unexpected text %q", result.Text)
+	}
+
+	if len(result.Segments) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(result.Segments))
+	}
+
+	first, last := result.Segments[0], result.Segments[1]
+	if len(first.Words) != 2 || first.Words[0].Start != 100 || first.Words[0].Probability != 1 {
+		t.Errorf("Unexpected words of the first segment %+v", first.Words)
+	}
+
+	// Kenobi starts after the last segment ends
+	if len(last.Words) != 2 || last.Words[1].Text != "Kenobi." || last.End != 2400 {
+		t.Errorf("Words after the last segment were lost: %+v, end %d", last.Words, last.End)
+	}
+}
+
+func TestRemoteProcessErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"api error", http.StatusUnauthorized, `{"error": {"message": "Invalid API key"}}`, "401 Unauthorized: Invalid API key"},
+		{"plain error", http.StatusBadGateway, "bad gateway", "502 Bad Gateway"},
+		{"invalid json", http.StatusOK, "not json", "Invalid remote engine response"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, _ := newStubEngine(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+
+			_, err := engine.Process("whisper-1", stubAudio, "en", nil)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Expected error with %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRemoteProcessTimeout(t *testing.T) {
+	done := make(chan struct{})
+	engine, cfg := newStubEngine(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer close(done)
+
+	cfg.RemoteTimeout = 1
+
+	start := time.Now()
+	_, err := engine.Process("whisper-1", stubAudio, "en", nil)
+	if err == nil || !strings.Contains(err.Error(), "Couldn't reach the remote engine") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Request wasn't cut off by RemoteTimeout, took %s", elapsed)
+	}
+}
+
+func TestRemoteResponseLanguage(t *testing.T) {
+	tests := map[string]string{
+		"english":   "en",
+		"Ukrainian": "uk",
+		"de":        "de",
+		"":          "",
+	}
+
+	for language, want := range tests {
+		response := remoteResponse{Language: language}
+		if got := response.transcript().Language; got != want {
+			t.Errorf("Language %q: expected %q, got %q", language, want, got)
+		}
+	}
+}
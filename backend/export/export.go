@@ -0,0 +1,237 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/notes"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// Formats notes can be exported to
+const (
+	// Folder per note with its Markdown, recordings and their transcripts
+	FormatZip = "zip"
+	// Single page with audio players and transcripts following playback
+	FormatHTML = "html"
+	// Text of the notes with transcripts under timestamps, without audio
+	FormatMarkdown = "markdown"
+)
+
+// Extension of files in the format, with the dot
+func Extension(format string) (string, error) {
+	switch format {
+	case FormatZip:
+		return ".zip", nil
+	case FormatHTML:
+		return ".html", nil
+	case FormatMarkdown:
+		return ".md", nil
+	}
+
+	return "", fmt.Errorf("Unknown export format %q", format)
+}
+
+type recording struct {
+	id string
+	// File name without extension
+	name string
+	// Zero when the recording id isn't a unix time
+	recorded   time.Time
+	duration   time.Duration
+	audioPath  string
+	text       string
+	transcript *transcript.Transcript
+}
+
+type note struct {
+	id string
+	// File name without extension, unique within the export
+	name       string
+	meta       *notes.Metadata
+	text       string
+	recordings []recording
+}
+
+func (n *note) title() string {
+	if n.meta.Title != "" {
+		return n.meta.Title
+	}
+
+	return "Untitled"
+}
+
+func (r *recording) title() string {
+	if r.recorded.IsZero() {
+		return "Recording " + r.id
+	}
+
+	return r.recorded.Local().Format("2 January 2006 15:04")
+}
+
+// Appends a number to names taken already, case insensitive like the
+// file systems of Windows and macOS
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[strings.ToLower(unique)]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+func loadNote(info *notes.NoteInfo) (*note, error) {
+	meta, err := info.ReadMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := info.ReadData()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	audios, err := info.ListAudio()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't list recordings: %w", err)
+	}
+
+	n := &note{id: info.Id, meta: meta, text: strings.TrimSpace(text)}
+	used := map[string]bool{}
+
+	for _, audio := range audios {
+		r := recording{
+			id:         audio.Id,
+			audioPath:  audio.AudioPath,
+			text:       strings.TrimSpace(audio.Text),
+			transcript: audio.Transcript,
+		}
+
+		name := audio.Id
+		if seconds, err := strconv.ParseInt(audio.Id, 10, 64); err == nil {
+			r.recorded = time.Unix(seconds, 0)
+			name = r.recorded.Local().Format("2006-01-02 15-04-05")
+		}
+		r.name = uniqueName(name, used)
+
+		if duration, err := notes.RecordingDuration(audio.AudioPath); err == nil {
+			r.duration = duration
+		} else {
+			fmt.Println("Couldn't read recording duration:", err)
+		}
+
+		n.recordings = append(n.recordings, r)
+	}
+
+	return n, nil
+}
+
+// Writes the notes to w in the format. Several notes end up in a single
+// file: folders of a ZIP, sections of a page or of a Markdown document
+func Export(w io.Writer, format string, infos []*notes.NoteInfo) error {
+	if len(infos) == 0 {
+		return errors.New("No notes to export")
+	}
+
+	exported := []*note{}
+	used := map[string]bool{}
+
+	for _, info := range infos {
+		n, err := loadNote(info)
+		if err != nil {
+			return fmt.Errorf("Couldn't export note %s: %w", info.Id, err)
+		}
+
+		n.name = uniqueName(notes.FileName(n.title()), used)
+		exported = append(exported, n)
+	}
+
+	switch format {
+	case FormatZip:
+		return writeZip(w, exported)
+	case FormatHTML:
+		return writeHTML(w, exported)
+	case FormatMarkdown:
+		return writeMarkdown(w, exported)
+	}
+
+	return fmt.Errorf("Unknown export format %q", format)
+}
+
+// Part of a transcript said by one speaker, or a single segment when
+// speakers weren't detected
+type transcriptLine struct {
+	Start   int64
+	End     int64
+	Speaker string
+	Text    string
+	// False when the text was edited and lost its timings
+	Timed bool
+}
+
+func (l transcriptLine) Time() string {
+	return formatTimestamp(l.Start)
+}
+
+// Segments only have the verbatim text, the clean one shown in the app is
+// exported without timings like edited text
+func transcriptLines(r recording, meta *notes.Metadata) []transcriptLine {
+	result := r.transcript
+	if result == nil || !result.SegmentsMatchText() || result.Clean != "" {
+		text := r.text
+		if result != nil {
+			text = strings.TrimSpace(result.DisplayText())
+		}
+
+		if text == "" {
+			return nil
+		}
+
+		return []transcriptLine{{Text: text}}
+	}
+
+	lines := []transcriptLine{}
+	for _, segment := range result.Segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+
+		speaker := ""
+		if segment.Speaker != 0 {
+			speaker = meta.SpeakerLabel(segment.Speaker)
+		}
+
+		if last := len(lines) - 1; last >= 0 && speaker != "" && lines[last].Speaker == speaker {
+			lines[last].Text += " " + text
+			lines[last].End = segment.End
+			continue
+		}
+
+		lines = append(lines, transcriptLine{
+			Start:   segment.Start,
+			End:     segment.End,
+			Speaker: speaker,
+			Text:    text,
+			Timed:   true,
+		})
+	}
+
+	return lines
+}
+
+// 01:23 or 1:02:03 for recordings longer than an hour
+func formatTimestamp(ms int64) string {
+	seconds := ms / 1000
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+//go:embed page.html
+var pageTemplate string
+
+var page = template.Must(template.New("page").Parse(pageTemplate))
+
+type pageRecording struct {
+	Title string
+	// Whole recording as a data URL, so the page works on its own
+	Audio template.URL
+	Lines []transcriptLine
+}
+
+type pageNote struct {
+	Anchor     string
+	Title      string
+	Created    string
+	Tags       []string
+	Text       string
+	Recordings []pageRecording
+}
+
+type pageData struct {
+	Title string
+	Notes []pageNote
+}
+
+func audioDataURL(audioPath string) (template.URL, error) {
+	audio, err := os.ReadFile(audioPath)
+	if err != nil {
+		return "", err
+	}
+
+	return template.URL("data:audio/wav;base64," + base64.StdEncoding.EncodeToString(audio)), nil
+}
+
+func writeHTML(w io.Writer, exported []*note) error {
+	data := pageData{Title: exported[0].title()}
+	if len(exported) > 1 {
+		data.Title = fmt.Sprintf("%d notes", len(exported))
+	}
+
+	for _, n := range exported {
+		pn := pageNote{
+			Anchor: "note-" + n.id,
+			Title:  n.title(),
+			Tags:   n.meta.Tags,
+			Text:   n.text,
+		}
+
+		if !n.meta.Created.IsZero() {
+			pn.Created = n.meta.Created.Local().Format("2 January 2006 15:04")
+		}
+
+		for _, r := range n.recordings {
+			audio, err := audioDataURL(r.audioPath)
+			if err != nil {
+				return fmt.Errorf("Couldn't read recording %s: %w", r.id, err)
+			}
+
+			pn.Recordings = append(pn.Recordings, pageRecording{
+				Title: r.title(),
+				Audio: audio,
+				Lines: transcriptLines(r, n.meta),
+			})
+		}
+
+		data.Notes = append(data.Notes, pn)
+	}
+
+	if err := page.Execute(w, data); err != nil {
+		return fmt.Errorf("Couldn't write page: %w", err)
+	}
+
+	return nil
+}
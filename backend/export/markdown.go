@@ -0,0 +1,114 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Folder of recordings next to the Markdown file in a ZIP
+const recordingsDir = "recordings"
+
+// Metadata goes into the frontmatter for a single note. Several notes in
+// one document get it as a line under their heading instead
+func markdownNote(n *note, frontmatter bool, audioDir string) (string, error) {
+	var b strings.Builder
+
+	if frontmatter {
+		// Links of the vault layout point into it, recordings of a ZIP are
+		// in its own folders and a Markdown document has none
+		meta := *n.meta
+		meta.Recordings = nil
+		if audioDir != "" && len(n.meta.Recordings) > 0 {
+			for _, r := range n.recordings {
+				meta.Recordings = append(meta.Recordings, fmt.Sprintf("[[%s]]", path.Join(n.name, audioDir, r.name+".wav")))
+			}
+		}
+
+		header, err := yaml.Marshal(&meta)
+		if err != nil {
+			return "", fmt.Errorf("Couldn't write metadata of note %s: %w", n.id, err)
+		}
+
+		fmt.Fprintf(&b, "---\n%s---\n\n", header)
+	}
+
+	// Notes often start with their title already
+	heading, text := "# "+n.title(), n.text
+	if strings.HasPrefix(text, "# ") {
+		heading, text, _ = strings.Cut(text, "\n")
+		text = strings.TrimSpace(text)
+	}
+	fmt.Fprintf(&b, "%s\n\n", heading)
+
+	if !frontmatter {
+		details := []string{}
+		if !n.meta.Created.IsZero() {
+			details = append(details, "Created "+n.meta.Created.Local().Format("2 January 2006 15:04"))
+		}
+
+		for _, tag := range n.meta.Tags {
+			details = append(details, "#"+strings.ReplaceAll(tag, " ", "-"))
+		}
+
+		if len(details) > 0 {
+			fmt.Fprintf(&b, "_%s_\n\n", strings.Join(details, " · "))
+		}
+	}
+
+	if text != "" {
+		fmt.Fprintf(&b, "%s\n\n", text)
+	}
+
+	if len(n.recordings) > 0 {
+		b.WriteString("## Recordings\n\n")
+	}
+
+	for _, r := range n.recordings {
+		fmt.Fprintf(&b, "### %s", r.title())
+		if r.duration > 0 {
+			fmt.Fprintf(&b, " (%s)", formatTimestamp(r.duration.Milliseconds()))
+		}
+		b.WriteString("\n\n")
+
+		if audioDir != "" {
+			link := (&url.URL{Path: path.Join(audioDir, r.name+".wav")}).EscapedPath()
+			fmt.Fprintf(&b, "[Audio](%s)\n\n", link)
+		}
+
+		for _, line := range transcriptLines(r, n.meta) {
+			if line.Timed {
+				fmt.Fprintf(&b, "**[%s]** ", line.Time())
+			}
+
+			if line.Speaker != "" {
+				fmt.Fprintf(&b, "%s: ", line.Speaker)
+			}
+
+			fmt.Fprintf(&b, "%s\n\n", line.Text)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
+
+func writeMarkdown(w io.Writer, exported []*note) error {
+	single := len(exported) == 1
+
+	parts := []string{}
+	for _, n := range exported {
+		part, err := markdownNote(n, single, "")
+		if err != nil {
+			return err
+		}
+
+		parts = append(parts, part)
+	}
+
+	_, err := io.WriteString(w, strings.Join(parts, "\n* * *\n\n"))
+	return err
+}
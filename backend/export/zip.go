@@ -0,0 +1,88 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+func zipFile(archive *zip.Writer, name string, method uint16, modified time.Time) (io.Writer, error) {
+	return archive.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: modified,
+	})
+}
+
+func zipRecording(archive *zip.Writer, dir string, r recording) error {
+	modified := r.recorded
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	audio, err := os.Open(r.audioPath)
+	if err != nil {
+		return fmt.Errorf("Couldn't read recording %s: %w", r.id, err)
+	}
+	defer audio.Close()
+
+	// Audio barely compresses, storing it keeps large exports fast
+	file, err := zipFile(archive, path.Join(dir, r.name+".wav"), zip.Store, modified)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, audio); err != nil {
+		return fmt.Errorf("Couldn't write recording %s: %w", r.id, err)
+	}
+
+	if r.text == "" {
+		return nil
+	}
+
+	file, err = zipFile(archive, path.Join(dir, r.name+".txt"), zip.Deflate, modified)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(file, r.text+"\n")
+	return err
+}
+
+// <Title>/<Title>.md linking to <Title>/recordings/<date>.wav with the
+// transcript of each recording next to it
+func writeZip(w io.Writer, exported []*note) error {
+	archive := zip.NewWriter(w)
+
+	for _, n := range exported {
+		modified := n.meta.Created
+		if modified.IsZero() {
+			modified = time.Now()
+		}
+
+		text, err := markdownNote(n, true, recordingsDir)
+		if err != nil {
+			return err
+		}
+
+		file, err := zipFile(archive, path.Join(n.name, n.name+".md"), zip.Deflate, modified)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(file, text); err != nil {
+			return err
+		}
+
+		for _, r := range n.recordings {
+			if err := zipRecording(archive, path.Join(n.name, recordingsDir), r); err != nil {
+				return err
+			}
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,66 @@
+package fronthelpers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/henmalib/whisper-notes/backend/export"
+	"github.com/henmalib/whisper-notes/backend/notes"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+var exportFilters = map[string]runtime.FileFilter{
+	export.FormatZip:      {DisplayName: "ZIP archive (*.zip)", Pattern: "*.zip"},
+	export.FormatHTML:     {DisplayName: "Web page (*.html)", Pattern: "*.html"},
+	export.FormatMarkdown: {DisplayName: "Markdown (*.md)", Pattern: "*.md"},
+}
+
+// Asks where to save the notes and exports them there in the format, see
+// export.Format*. Returns the saved file, empty when the dialog was cancelled
+func (h *FrontHelpers) ExportNotes(noteIds []string, format string) (string, error) {
+	extension, err := export.Extension(format)
+	if err != nil {
+		return "", err
+	}
+
+	infos := []*notes.NoteInfo{}
+	for _, noteId := range noteIds {
+		info := h.noteCreator.FindNote(noteId)
+		if info == nil {
+			return "", fmt.Errorf("Note %s doesn't exist", noteId)
+		}
+
+		infos = append(infos, info)
+	}
+
+	name := "Notes"
+	if len(infos) == 1 && infos[0].Metadata != nil {
+		name = notes.FileName(infos[0].Metadata.Title)
+	}
+
+	target, err := runtime.SaveFileDialog(h.ctx, runtime.SaveDialogOptions{
+		Title:           "Export notes",
+		DefaultFilename: name + extension,
+		Filters:         []runtime.FileFilter{exportFilters[format]},
+	})
+	if err != nil || target == "" {
+		return "", err
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return "", fmt.Errorf("Couldn't create %s: %w", target, err)
+	}
+
+	if err := export.Export(file, format, infos); err != nil {
+		file.Close()
+		os.Remove(target)
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("Couldn't write %s: %w", target, err)
+	}
+
+	return target, nil
+}
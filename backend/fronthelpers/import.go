@@ -0,0 +1,13 @@
+package fronthelpers
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Folder with Markdown notes to pass to Notes.ImportMarkdown, empty when
+// the dialog was cancelled
+func (h *FrontHelpers) ChooseImportFolder() (string, error) {
+	return runtime.OpenDirectoryDialog(h.ctx, runtime.OpenDialogOptions{
+		Title: "Import notes from a folder or Obsidian vault",
+	})
+}
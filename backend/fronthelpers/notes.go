@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/henmalib/whisper-notes/backend/notes"
+	"github.com/henmalib/whisper-notes/backend/transcript"
 )
 
 func (h *FrontHelpers) GetNoteMetadata(n *notes.NoteInfo) (*notes.Metadata, error) {
@@ -47,6 +48,81 @@ func (h *FrontHelpers) GetNoteText(n *notes.NoteInfo) (string, error) {
 	return data, nil
 }
 
-func (h *FrontHelpers) SaveNote(id string, text string, metadata *notes.Metadata) error {
-	return notes.UpdateNote(id, text, metadata)
+// Version is the one the note was loaded with, empty overwrites without a
+// check. Conflicts are returned as notes.ConflictError with both versions
+func (h *FrontHelpers) SaveNote(id string, text string, metadata *notes.Metadata, version string) (string, error) {
+	return notes.UpdateNote(id, text, metadata, version)
+}
+
+// Threshold of 0 falls back to the configured one
+func (h *FrontHelpers) GetLowConfidenceSpans(n *notes.NoteInfo, recordingId string, threshold float32) ([]transcript.Span, error) {
+	result, err := n.ReadTranscript(recordingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if threshold <= 0 {
+		threshold = float32(h.cfg.GetConfig().LowConfidenceThreshold)
+	}
+
+	return result.LowConfidenceSpans(threshold), nil
+}
+
+// Word said at the playback position, nil before the first word
+func (h *FrontHelpers) GetWordAt(n *notes.NoteInfo, recordingId string, ms int64) (*transcript.WordMatch, error) {
+	result, err := n.ReadTranscript(recordingId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.WordAt(ms), nil
+}
+
+// Word at the offset of the text shown in the note, its Start is where playback should seek to
+func (h *FrontHelpers) GetWordAtOffset(n *notes.NoteInfo, recordingId string, offset int) (*transcript.WordMatch, error) {
+	result, err := n.ReadTranscript(recordingId)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.WordAtOffset(offset), nil
+}
+
+// Defaults for the clean text toggles, taken from the config
+func (h *FrontHelpers) GetCleanOptions() transcript.CleanOptions {
+	return cleanOptions(h.cfg.GetConfig())
+}
+
+// Cleans stored verbatim text with the given stages, so toggles can be previewed
+func (h *FrontHelpers) GetCleanText(n *notes.NoteInfo, recordingId string, opts transcript.CleanOptions) (string, error) {
+	result, err := n.ReadTranscript(recordingId)
+	if err != nil {
+		return "", err
+	}
+
+	return result.CleanText(opts), nil
+}
+
+func (h *FrontHelpers) SetSpeakerLabel(n *notes.NoteInfo, speaker int, label string) error {
+	return n.SetSpeakerLabel(speaker, label)
+}
+
+func (h *FrontHelpers) GetDialogue(n *notes.NoteInfo, recordingId string) ([]notes.DialogueLine, error) {
+	return n.GetDialogue(recordingId)
+}
+
+func (h *FrontHelpers) ListRevisions(n *notes.NoteInfo, recordingId string) ([]notes.Revision, error) {
+	return n.ListRevisions(recordingId)
+}
+
+func (h *FrontHelpers) DiffRevisions(n *notes.NoteInfo, recordingId, fromId, toId string) ([]transcript.DiffOp, error) {
+	return n.DiffRevisions(recordingId, fromId, toId)
+}
+
+func (h *FrontHelpers) RestoreRevision(n *notes.NoteInfo, recordingId, revisionId string) error {
+	return n.RestoreRevision(recordingId, revisionId)
+}
+
+func (h *FrontHelpers) EditTranscript(n *notes.NoteInfo, recordingId, text string) error {
+	return n.EditTranscript(recordingId, text)
 }
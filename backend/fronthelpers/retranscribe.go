@@ -0,0 +1,100 @@
+package fronthelpers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/henmalib/whisper-notes/backend/notes"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+	"github.com/henmalib/whisper-notes/backend/wav"
+)
+
+// Empty fields fall back to the profile, then the config
+type RetranscribeOptions struct {
+	// Empty picks DefaultProfile
+	Profile string `json:"profile"`
+	// "local" or "remote"
+	Engine   string `json:"engine"`
+	Model    string `json:"model"`
+	Language string `json:"language"`
+}
+
+func (h *FrontHelpers) retranscribe(n *notes.NoteInfo, recordingId string, opts RetranscribeOptions, progress func(int)) (*transcript.Transcript, error) {
+	audioBytes, err := n.ReadAudio(recordingId)
+	if err != nil {
+		return nil, err
+	}
+
+	data, sampleRate, err := wav.Decode(audioBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't decode recording %s: %w", recordingId, err)
+	}
+	data = wav.Resample(data, sampleRate, wav.SampleRate)
+
+	cfg := h.cfg.GetConfig()
+
+	profile, err := cfg.FindProfile(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.Engine == "" {
+		profile.Engine = cfg.Engine
+	}
+
+	// A different engine doesn't know the model of the profile
+	if opts.Engine != "" && opts.Engine != profile.Engine {
+		profile.Engine, profile.Model = opts.Engine, ""
+	}
+
+	if opts.Model != "" {
+		profile.Model = opts.Model
+	}
+
+	if opts.Language != "" {
+		profile.Language = opts.Language
+	}
+
+	choice := h.engine(cfg, profile)
+
+	result, cleanup, err := h.transcribe(choice, data, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	result = h.postProcess(data, result)
+	if err := n.ReplaceTranscript(recordingId, result); err != nil {
+		return nil, fmt.Errorf("Couldn't save the transcript: %w", err)
+	}
+
+	cleanup()
+
+	return result, nil
+}
+
+// Transcribes a saved recording again, the previous transcript is kept as an older version
+func (h *FrontHelpers) RetranscribeRecording(n *notes.NoteInfo, recordingId string, opts RetranscribeOptions, toastId string) (*transcript.Transcript, error) {
+	return h.retranscribe(n, recordingId, opts, h.progressReporter(toastId))
+}
+
+// Transcribes every recording of the note again. Failed recordings don't stop the rest
+func (h *FrontHelpers) RetranscribeNote(n *notes.NoteInfo, opts RetranscribeOptions, toastId string) error {
+	recordings, err := n.ListAudio()
+	if err != nil {
+		return err
+	}
+
+	progress := h.progressReporter(toastId)
+	errs := []error{}
+
+	for i, recording := range recordings {
+		_, err := h.retranscribe(n, recording.Id, opts, func(p int) {
+			progress((i*100 + p) / len(recordings))
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Recording %s: %w", recording.Id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
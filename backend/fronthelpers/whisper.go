@@ -6,50 +6,245 @@ import (
 
 	"github.com/henmalib/whisper-notes/backend/audio"
 	"github.com/henmalib/whisper-notes/backend/config"
+	"github.com/henmalib/whisper-notes/backend/engine"
 	"github.com/henmalib/whisper-notes/backend/notes"
-	"github.com/henmalib/whisper-notes/backend/whisper"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+	"github.com/henmalib/whisper-notes/backend/wav"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 type FrontHelpers struct {
 	ctx         context.Context
 	cfg         config.ConfigLoader
-	whisper     *whisper.Whisper
+	local       engine.Engine
+	remote      engine.Engine
 	noteCreator NoteCreator
 }
 
 type NoteCreator interface {
-	CreateNote(title string) (string, error)
+	CreateNoteFrom(title string, source string) (string, error)
 	FindNote(id string) *notes.NoteInfo
 }
 
-func NewHelpers(ctx context.Context, cfg config.ConfigLoader, whisper *whisper.Whisper, notes NoteCreator) FrontHelpers {
+func NewHelpers(ctx context.Context, cfg config.ConfigLoader, local engine.Engine, notes NoteCreator) FrontHelpers {
 	return FrontHelpers{
 		ctx,
 		cfg,
-		whisper,
+		local,
+		engine.NewRemote(cfg),
 		notes,
 	}
 }
 
+type engineChoice struct {
+	engine   engine.Engine
+	name     string
+	model    string
+	language string
+	profile  string
+}
+
+// Config with the remote url of a profile
+type profileConfig struct {
+	cfg config.Config
+}
+
+func (p profileConfig) GetConfig() *config.Config {
+	return &p.cfg
+}
+
+// Engine, model and language of the profile, fields it leaves empty come
+// from the config
+func (h *FrontHelpers) engine(cfg *config.Config, profile config.Profile) engineChoice {
+	choice := engineChoice{
+		name:     profile.Engine,
+		model:    profile.Model,
+		language: profile.Language,
+		profile:  profile.Name,
+	}
+
+	if choice.name == "" {
+		choice.name = cfg.Engine
+	}
+
+	if choice.language == "" {
+		choice.language = cfg.PreferedLanguage
+	}
+
+	if choice.name != engine.Remote {
+		choice.name, choice.engine = engine.Local, h.local
+		if choice.model == "" {
+			choice.model = cfg.CurrentModel
+		}
+
+		return choice
+	}
+
+	choice.engine = h.remote
+	if profile.RemoteUrl != "" {
+		remoteCfg := *cfg
+		remoteCfg.RemoteUrl = profile.RemoteUrl
+		choice.engine = engine.NewRemote(profileConfig{remoteCfg})
+	}
+
+	if choice.model == "" {
+		choice.model = cfg.RemoteModel
+	}
+
+	return choice
+}
+
+// Runs long recordings in checkpointed chunks and mixed languages chunk by
+// chunk when the engine supports it. Returned cleanup removes the checkpoint,
+// call it once the result is saved
+func (h *FrontHelpers) transcribe(choice engineChoice, data []float32, progress func(int)) (*transcript.Transcript, func(), error) {
+	cfg := h.cfg.GetConfig()
+	eng, modelname, language := choice.engine, choice.model, choice.language
+	long, isLong := eng.(engine.LongEngine)
+	mixed, isMixed := eng.(engine.MixedLanguageEngine)
+
+	var result *transcript.Transcript
+	var jobId string
+	var err error
+
+	switch {
+	case isLong && cfg.LongAudioSeconds > 0 && len(data) > cfg.LongAudioSeconds*wav.SampleRate:
+		result, jobId, err = long.ProcessLong(modelname, data, language, progress)
+	case isMixed && language == "auto" && cfg.LanguagePerChunk:
+		result, err = mixed.ProcessMixedLanguages(modelname, data, cfg.LanguageChunkSeconds, progress)
+	default:
+		result, err = eng.Process(modelname, data, language, progress)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Couldn't extract text from the audio: %w", err)
+	}
+
+	result.Engine = choice.name
+	result.Profile = choice.profile
+	result.Source = transcript.SourceTranscription
+
+	cleanup := func() {
+		if jobId != "" {
+			h.deleteLongJob(long, jobId)
+		}
+	}
+
+	return result, cleanup, nil
+}
+
 // I want to support uploading audio later on, so having data as an arg is useful!
-func (h *FrontHelpers) ProcessAndSaveNote(data []float32, language, toastId string) (string, error) {
-	modelname := h.cfg.GetConfig().CurrentModel
+// Empty profile picks DefaultProfile, empty language the one of the profile
+func (h *FrontHelpers) ProcessAndSaveNote(data []float32, language, profileName, toastId string) (string, error) {
+	cfg := h.cfg.GetConfig()
 
-	text, err := h.whisper.Process(modelname, data, language, func(i int) {
-		runtime.EventsEmit(h.ctx, fmt.Sprintf("whisper:audio:%s:progress", toastId), i)
-	})
+	profile, err := cfg.FindProfile(profileName)
+	if err != nil {
+		return "", err
+	}
+
+	choice := h.engine(cfg, profile)
+	if language != "" {
+		choice.language = language
+	}
+
+	result, cleanup, err := h.transcribe(choice, data, h.progressReporter(toastId))
+	if err != nil {
+		return "", err
+	}
+
+	noteId, err := h.saveNewNote(data, result)
+	if err != nil {
+		return "", err
+	}
+
+	cleanup()
+
+	return noteId, nil
+}
+
+// Finishes a long transcription interrupted by a crash or restart and saves it as a new note.
+// Only the local engine checkpoints, so jobs always belong to it
+func (h *FrontHelpers) ResumeLongTranscription(jobId, toastId string) (string, error) {
+	long, ok := h.local.(engine.LongEngine)
+	if !ok {
+		return "", fmt.Errorf("Local engine doesn't support long transcriptions")
+	}
 
+	result, err := long.ResumeLongJob(jobId, h.progressReporter(toastId))
 	if err != nil {
 		return "", fmt.Errorf("Couldn't extract text from the audio: %w", err)
 	}
 
+	result.Engine = engine.Local
+	result.Source = transcript.SourceTranscription
+
+	data, err := long.LongJobAudio(jobId)
+	if err != nil {
+		return "", err
+	}
+
+	noteId, err := h.saveNewNote(data, result)
+	if err != nil {
+		return "", err
+	}
+
+	h.deleteLongJob(long, jobId)
+
+	return noteId, nil
+}
+
+func (h *FrontHelpers) progressReporter(toastId string) func(int) {
+	return func(i int) {
+		runtime.EventsEmit(h.ctx, fmt.Sprintf("whisper:audio:%s:progress", toastId), i)
+	}
+}
+
+func (h *FrontHelpers) deleteLongJob(long engine.LongEngine, jobId string) {
+	if err := long.DeleteLongJob(jobId); err != nil {
+		fmt.Println("Couldn't remove transcription checkpoint:", err)
+	}
+}
+
+func cleanOptions(cfg *config.Config) transcript.CleanOptions {
+	return transcript.CleanOptions{
+		RemoveFillers:    cfg.RemoveFillers,
+		Paragraphs:       cfg.SplitParagraphs,
+		ParagraphPauseMs: int64(cfg.ParagraphPauseMs),
+		FixCasing:        cfg.FixCasing,
+		NormalizeNumbers: cfg.NormalizeNumbers,
+	}
+}
+
+// Hallucination filter and clean text, applied to every transcript before it's saved
+func (h *FrontHelpers) postProcess(data []float32, result *transcript.Transcript) *transcript.Transcript {
+	cfg := h.cfg.GetConfig()
+
+	if mode := cfg.HallucinationFilter; mode != "off" {
+		result = result.Filter(transcript.FilterOptions{
+			Remove:     mode == "remove",
+			Audio:      data,
+			SampleRate: wav.SampleRate,
+		})
+	}
+
+	// The pause alone doesn't change the text, only the stages do
+	if opts := cleanOptions(cfg); opts.RemoveFillers || opts.Paragraphs || opts.FixCasing || opts.NormalizeNumbers {
+		result.Clean = result.CleanText(opts)
+	}
+
+	return result
+}
+
+func (h *FrontHelpers) saveNewNote(data []float32, result *transcript.Transcript) (string, error) {
+	result = h.postProcess(data, result)
+
 	audioBytes, err := audio.Float32ToWavBytes(data)
 	if err != nil {
 		return "", fmt.Errorf("Couldn't convert audio to WAV: %w", err)
 	}
 
-	noteId, err := h.noteCreator.CreateNote("Unnamed")
+	noteId, err := h.noteCreator.CreateNoteFrom("Untitled", notes.SourceRecorded)
 	if err != nil {
 		return "", fmt.Errorf("Couldn't create a note: %w", err)
 	}
@@ -59,7 +254,9 @@ func (h *FrontHelpers) ProcessAndSaveNote(data []float32, language, toastId stri
 		return "", fmt.Errorf("Newly created note wasn't found? NoteId: %s", noteId)
 	}
 
-	note.AddAudio(audioBytes, text)
+	if err := note.AddAudio(audioBytes, result); err != nil {
+		return "", fmt.Errorf("Couldn't save the recording: %w", err)
+	}
 
 	return noteId, nil
 }
@@ -0,0 +1,13 @@
+package gitrepo
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// CREATE_NO_WINDOW, git would flash a console window otherwise
+const createNoWindow = 0x08000000
+
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: createNoWindow}
+}
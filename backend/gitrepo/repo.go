@@ -0,0 +1,256 @@
+package gitrepo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity used for commits when git has none configured
+const (
+	fallbackName  = "Whisper Notes"
+	fallbackEmail = "whisper-notes@localhost"
+)
+
+// Lines between these markers of .git/info/exclude are managed by the app
+const (
+	excludeStart = "# whisper-notes start"
+	excludeEnd   = "# whisper-notes end"
+)
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
+}
+
+// Repository driven through the git command line
+type Repo struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewRepo(path string) *Repo {
+	return &Repo{path: path}
+}
+
+// Git is installed and can be run
+func Available() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+func ValidateCommit(commit string) error {
+	if !commitPattern.MatchString(commit) {
+		return fmt.Errorf("Invalid commit %q", commit)
+	}
+
+	return nil
+}
+
+func (r *Repo) run(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.path}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	hideWindow(cmd)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+
+		return stdout.Bytes(), fmt.Errorf("git %s failed: %s", args[0], message)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Creates the repository unless the directory is one already
+func (r *Repo) Init() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(r.path, ".git")); err == nil {
+		return nil
+	}
+
+	_, err := r.run("init", "--quiet")
+	return err
+}
+
+// Keeps patterns out of commits without adding a .gitignore to the
+// directory. Files matching them that were committed before stop being tracked
+func (r *Repo) SetExcludes(patterns []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	excludePath := filepath.Join(r.path, ".git", "info", "exclude")
+
+	content, err := os.ReadFile(excludePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// Drops the block written before, lines of the user stay
+	kept := []string{}
+	managed := false
+	for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+		switch {
+		case line == excludeStart:
+			managed = true
+		case line == excludeEnd:
+			managed = false
+		case !managed && line != "":
+			kept = append(kept, line)
+		}
+	}
+
+	lines := append(kept, excludeStart)
+	lines = append(lines, patterns...)
+	lines = append(lines, excludeEnd)
+
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(excludePath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("Couldn't write git excludes: %w", err)
+	}
+
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	args := append([]string{"rm", "-r", "--cached", "--quiet", "--ignore-unmatch", "--"}, patterns...)
+	_, err = r.run(args...)
+	return err
+}
+
+func (r *Repo) identity() []string {
+	args := []string{}
+
+	if name, _ := r.run("config", "user.name"); len(bytes.TrimSpace(name)) == 0 {
+		args = append(args, "-c", "user.name="+fallbackName)
+	}
+
+	if email, _ := r.run("config", "user.email"); len(bytes.TrimSpace(email)) == 0 {
+		args = append(args, "-c", "user.email="+fallbackEmail)
+	}
+
+	return args
+}
+
+// Commits every change in the directory, false when there was nothing to commit
+func (r *Repo) CommitAll(message string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.run("add", "--all"); err != nil {
+		return false, err
+	}
+
+	// Exits with 1 when something is staged
+	if _, err := r.run("diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+
+	args := append(r.identity(), "commit", "--quiet", "--no-verify", "-m", message)
+	if _, err := r.run(args...); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Commits touching any of the paths, newest first. Limit of 0 returns all of them
+func (r *Repo) Log(limit int, paths ...string) ([]Commit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	commits := []Commit{}
+
+	args := []string{"log", "--format=%H%x1f%aI%x1f%s%x1e"}
+	if limit > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", limit))
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+
+	out, err := r.run(args...)
+	if err != nil {
+		// A repository without commits has no history yet
+		if strings.Contains(err.Error(), "does not have any commits") {
+			return commits, nil
+		}
+
+		return commits, err
+	}
+
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+
+		date, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return commits, fmt.Errorf("Invalid commit date %q: %w", fields[1], err)
+		}
+
+		commits = append(commits, Commit{Hash: fields[0], Date: date, Message: fields[2]})
+	}
+
+	return commits, nil
+}
+
+// Content of the file at the commit
+func (r *Repo) Show(commit, path string) ([]byte, error) {
+	if err := ValidateCommit(commit); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.run("show", commit+":"+filepath.ToSlash(path))
+}
+
+// Brings files under the paths back to how they were at the commit. Files
+// added later are kept, paths that didn't exist at the commit are skipped
+func (r *Repo) Restore(commit string, paths ...string) error {
+	if err := ValidateCommit(commit); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	args := append([]string{"ls-tree", "-z", "--name-only", commit, "--"}, paths...)
+	out, err := r.run(args...)
+	if err != nil {
+		return err
+	}
+
+	existing := strings.FieldsFunc(string(out), func(r rune) bool {
+		return r == 0
+	})
+	if len(existing) == 0 {
+		return fmt.Errorf("Nothing to restore at %s", commit)
+	}
+
+	_, err = r.run(append([]string{"checkout", commit, "--"}, existing...)...)
+	return err
+}
@@ -0,0 +1,91 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/config"
+)
+
+var ErrInvalidConfig = errors.New("Invalid network config")
+
+// Builds http client that honours proxy, custom CA and timeout settings.
+// Every network call of the app should go through it
+func NewClient(cfg *config.Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy, err := proxyFunc(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	transport.Proxy = proxy
+
+	if len(cfg.CaBundles) > 0 {
+		pool, err := certPool(cfg.CaBundles)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	// Timeout can't be applied to the whole request, downloading a model takes
+	// way longer than any sane timeout. Only limit the time until server responds
+	if cfg.RequestTimeout > 0 {
+		timeout := time.Duration(cfg.RequestTimeout) * time.Second
+
+		transport.DialContext = (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+		transport.TLSHandshakeTimeout = timeout
+		transport.ResponseHeaderTimeout = timeout
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func proxyFunc(cfg *config.Config) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.ProxyUrl == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyUrl, err := url.Parse(cfg.ProxyUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid proxy url: %w", err)
+	}
+
+	if cfg.ProxyUsername != "" {
+		proxyUrl.User = url.UserPassword(cfg.ProxyUsername, cfg.ProxyPassword)
+	}
+
+	return http.ProxyURL(proxyUrl), nil
+}
+
+func certPool(bundles []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		fmt.Println("Couldn't load system certificates:", err)
+		pool = x509.NewCertPool()
+	}
+
+	for _, bundle := range bundles {
+		bytes, err := os.ReadFile(os.ExpandEnv(bundle))
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't read CA bundle %s: %w", bundle, err)
+		}
+
+		if !pool.AppendCertsFromPEM(bytes) {
+			return nil, fmt.Errorf("CA bundle %s doesn't contain any PEM certificates", bundle)
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,94 @@
+package network
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Shared between every reader, so the limit applies to the app as a whole
+// and not to each download separately
+var sharedLimiter = &limiter{}
+
+type limiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+// Blocks until n bytes can be consumed under the given rate
+func (l *limiter) wait(ctx context.Context, n int, rate int64) error {
+	l.mu.Lock()
+
+	now := time.Now()
+	if l.rate != rate || l.last.IsZero() {
+		l.rate = rate
+		l.tokens = 0
+		l.last = now
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * float64(rate)
+	// Don't let idle time accumulate into a huge burst
+	l.tokens = min(l.tokens, float64(rate))
+	l.last = now
+	l.tokens -= float64(n)
+
+	delay := time.Duration(0)
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / float64(rate) * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type limitedReader struct {
+	ctx  context.Context
+	r    io.Reader
+	rate int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Smaller reads keep the transfer smooth instead of bursty
+	if int64(len(p)) > r.rate {
+		p = p[:r.rate]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := sharedLimiter.wait(r.ctx, n, r.rate); werr != nil {
+			return n, werr
+		}
+	}
+
+	return n, err
+}
+
+// Wraps reader so it doesn't read faster than kbps kilobytes per second,
+// 0 means no limit
+func LimitReader(ctx context.Context, r io.Reader, kbps int) io.Reader {
+	if kbps <= 0 {
+		return r
+	}
+
+	return &limitedReader{
+		ctx:  ctx,
+		r:    r,
+		rate: int64(kbps) * 1024,
+	}
+}
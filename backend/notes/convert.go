@@ -0,0 +1,408 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+type convertedNote struct {
+	from, to     Layout
+	oldId, newId string
+}
+
+// Moves notes from one layout into another, remembering every move so links
+// can follow the files and a failed conversion can be undone
+type layoutConverter struct {
+	// New paths of moved files by their old paths
+	files map[string]string
+	// Converted notes by their old ids, for wikilinks
+	ids   map[string]convertedNote
+	notes []convertedNote
+	// Renames done so far, old and new path
+	moves [][2]string
+}
+
+func newLayoutConverter() *layoutConverter {
+	return &layoutConverter{
+		files: map[string]string{},
+		ids:   map[string]convertedNote{},
+	}
+}
+
+// Every file under the folder, or the file itself
+func listFiles(filePath string) []string {
+	entries, err := os.ReadDir(filePath)
+	if err != nil {
+		if stat, err := os.Stat(filePath); err == nil && !stat.IsDir() {
+			return []string{filePath}
+		}
+
+		return nil
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		files = append(files, listFiles(path.Join(filePath, entry.Name()))...)
+	}
+
+	return files
+}
+
+// Removes the folder and its parents up to the root as long as they're empty
+func removeEmptyDirs(root, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			removeEmptyDirs(dir, path.Join(dir, entry.Name()))
+		}
+	}
+
+	for ; dir != root && strings.HasPrefix(dir, root+"/"); dir = path.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// Recordings are named by unix time, their transcripts share the name.
+// Dot files, like the trash info, stay with the recordings too
+func isRecordingFile(dir, name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+
+	recordingId, _, _ := strings.Cut(name, ".")
+	_, err := os.Stat(path.Join(dir, recordingId+".wav"))
+
+	return err == nil
+}
+
+func (c *layoutConverter) move(source, target string) error {
+	if _, err := os.Lstat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+
+	if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(source, target); err != nil {
+		return err
+	}
+
+	c.moves = append(c.moves, [2]string{source, target})
+	c.files[source] = target
+
+	return nil
+}
+
+func (c *layoutConverter) undo() {
+	for i := len(c.moves) - 1; i >= 0; i-- {
+		source, target := c.moves[i][0], c.moves[i][1]
+
+		err := os.MkdirAll(path.Dir(source), 0755)
+		if err == nil {
+			err = os.Rename(target, source)
+		}
+
+		if err != nil {
+			fmt.Println("Couldn't move back note file:", err)
+		}
+	}
+}
+
+// Target of every file of the note in the other layout
+func (c *layoutConverter) noteFiles(from, to Layout, noteId, newId string) map[string]string {
+	recordingsDir := from.RecordingsDir(noteId)
+	attachmentsDir := from.AttachmentsDir(noteId)
+
+	targets := map[string]string{}
+	for _, notePath := range from.NotePaths(noteId) {
+		for _, file := range listFiles(path.Join(from.Root(), notePath)) {
+			switch {
+			case file == from.NoteFile(noteId):
+				targets[file] = to.NoteFile(newId)
+			case attachmentsDir != recordingsDir && strings.HasPrefix(file, attachmentsDir+"/"):
+				targets[file] = path.Join(to.AttachmentsDir(newId), strings.TrimPrefix(file, attachmentsDir+"/"))
+			case path.Dir(file) == recordingsDir && isRecordingFile(recordingsDir, path.Base(file)):
+				targets[file] = path.Join(to.RecordingsDir(newId), path.Base(file))
+			case strings.HasPrefix(file, path.Join(recordingsDir, versionsDir)+"/"):
+				rel := strings.TrimPrefix(file, path.Join(recordingsDir, versionsDir)+"/")
+				targets[file] = path.Join(to.RecordingsDir(newId), versionsDir, rel)
+			default:
+				// Images and other files put next to the recordings
+				rel, found := strings.CutPrefix(file, recordingsDir+"/")
+				if !found {
+					rel = path.Base(file)
+				}
+
+				targets[file] = path.Join(to.AttachmentsDir(newId), rel)
+			}
+		}
+	}
+
+	return targets
+}
+
+func (c *layoutConverter) convertNote(from, to Layout, noteId string) error {
+	title := ""
+	if header, _, err := readNoteFileAt(from.NoteFile(noteId)); err == nil {
+		if meta, err := decodeMetadata(header); err == nil {
+			title = meta.Title
+		}
+	}
+
+	if title == "" {
+		title = noteId
+	}
+
+	// Files are listed before moving any, so the new id isn't taken by them
+	newId := to.NewNoteId(title)
+	for file, target := range c.noteFiles(from, to, noteId, newId) {
+		if err := c.move(file, target); err != nil {
+			return fmt.Errorf("Couldn't move %s: %w", file, err)
+		}
+	}
+
+	for _, notePath := range from.NotePaths(noteId) {
+		removeEmptyDirs(from.Root(), path.Join(from.Root(), notePath))
+	}
+
+	note := convertedNote{from: from, to: to, oldId: noteId, newId: newId}
+	c.notes = append(c.notes, note)
+	if _, found := c.ids[noteId]; !found {
+		c.ids[noteId] = note
+	}
+
+	return nil
+}
+
+func (c *layoutConverter) convertRoot(from, to Layout) error {
+	noteIds, err := from.NoteIds()
+	if err != nil {
+		return fmt.Errorf("Couldn't list notes in %s: %w", from.Root(), err)
+	}
+
+	for _, noteId := range noteIds {
+		if _, err := noteStat(from, noteId); err != nil {
+			continue
+		}
+
+		if err := c.convertNote(from, to, noteId); err != nil {
+			return fmt.Errorf("Couldn't convert note %s: %w", noteId, err)
+		}
+	}
+
+	return nil
+}
+
+// Relative links resolve against the folder of the note file, which moves
+func (c *layoutConverter) markdownLink(note convertedNote, match string) string {
+	parts := markdownLinkPattern.FindStringSubmatch(match)
+	target := strings.TrimSuffix(strings.TrimPrefix(parts[3], "<"), ">")
+
+	if urlSchemePattern.MatchString(target) || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "/") {
+		return match
+	}
+
+	target, fragment, hasFragment := strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	linked := path.Join(path.Dir(note.from.NoteFile(note.oldId)), target)
+	if moved, ok := c.files[linked]; ok {
+		linked = moved
+	} else if _, err := os.Stat(linked); err != nil {
+		// Broken already, leave it for the user to fix
+		return match
+	}
+
+	link := relativeLinkFrom(note.to.NoteFile(note.newId), linked)
+	if hasFragment {
+		link += "#" + fragment
+	}
+
+	return fmt.Sprintf("%s[%s](%s%s)", parts[1], parts[2], link, parts[4])
+}
+
+// Wikilinks name notes or files in the vault. They stay wikilinks in a vault
+// and become relative links otherwise
+func (c *layoutConverter) wikiLink(note convertedNote, match string) string {
+	parts := wikiLinkPattern.FindStringSubmatch(match)
+	target, label, hasLabel := strings.Cut(parts[2], "|")
+	target, fragment, hasFragment := strings.Cut(target, "#")
+
+	linked := ""
+	if other, ok := c.ids[strings.TrimSuffix(target, ".md")]; ok {
+		linked = other.to.NoteFile(other.newId)
+	} else if moved, ok := c.files[path.Join(note.from.AttachmentsDir(note.oldId), target)]; ok {
+		linked = moved
+	} else if moved, ok := c.files[path.Join(note.from.Root(), target)]; ok {
+		linked = moved
+	} else {
+		return match
+	}
+
+	if note.to.Name() == LayoutVault {
+		rel := strings.TrimPrefix(linked, note.to.Root()+"/")
+		if linked == note.to.NoteFile(strings.TrimSuffix(rel, ".md")) {
+			rel = strings.TrimSuffix(rel, ".md")
+		}
+
+		if hasFragment {
+			rel += "#" + fragment
+		}
+
+		if hasLabel {
+			rel += "|" + label
+		}
+
+		return fmt.Sprintf("%s[[%s]]", parts[1], rel)
+	}
+
+	link := relativeLinkFrom(note.to.NoteFile(note.newId), linked)
+	// Block references have no equivalent, the link goes to the note
+	if hasFragment && !strings.HasPrefix(fragment, "^") {
+		link += "#" + headingAnchor(fragment)
+	}
+
+	if !hasLabel || imageSizePattern.MatchString(label) {
+		label = strings.TrimSuffix(path.Base(target), path.Ext(target))
+	}
+
+	return fmt.Sprintf("%s[%s](%s)", parts[1], label, link)
+}
+
+func (c *layoutConverter) rewriteLinks(note convertedNote) error {
+	noteFile := note.to.NoteFile(note.newId)
+
+	header, body, err := readNoteFileAt(noteFile)
+	if err != nil {
+		return err
+	}
+
+	rewritten := outsideCode(body, func(text string) string {
+		return linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+			if strings.HasPrefix(strings.TrimPrefix(match, "!"), "[[") {
+				return c.wikiLink(note, match)
+			}
+
+			return c.markdownLink(note, match)
+		})
+	})
+
+	if rewritten == body {
+		return nil
+	}
+
+	content, err := joinFrontmatter(header, rewritten)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(noteFile, []byte(content), 0600)
+}
+
+// Moves every note, trashed ones too, into the layout and switches
+// NotesLayout to it. Links between notes and to their files follow them.
+// Nothing is moved when a note can't be converted. Returns the new ids of
+// the notes by their old ones
+func (n *Notes) ConvertLayout(layoutName string) (map[string]string, error) {
+	ids := map[string]string{}
+
+	from := getLayout()
+	to, err := newLayout(layoutName, from.Root())
+	if err != nil {
+		return ids, err
+	}
+
+	if from.Name() == to.Name() {
+		return ids, fmt.Errorf("Notes are already stored in the %s layout", to.Name())
+	}
+
+	// Changes made before go into the history under their old paths
+	h := getHistory()
+	if h != nil {
+		h.commit()
+	}
+
+	trashTo, _ := newLayout(layoutName, getTrashPath())
+	c := newLayoutConverter()
+
+	// Every move would be reported as a deleted and a new note, and folders
+	// of the new layout wouldn't be watched. Watching starts over once done
+	watching := stopWatching()
+
+	saveMu.Lock()
+	err = c.convertRoot(from, to)
+	if err == nil {
+		err = c.convertRoot(getTrashLayout(), trashTo)
+	}
+
+	if err != nil {
+		c.undo()
+		saveMu.Unlock()
+
+		if watching {
+			if err := n.Watch(); err != nil {
+				fmt.Println("Error while watching notes:", err)
+			}
+		}
+
+		return ids, fmt.Errorf("%w, no notes were moved", err)
+	}
+
+	errs := []error{}
+	for _, note := range c.notes {
+		ids[note.oldId] = note.newId
+
+		if err := c.rewriteLinks(note); err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't update links of note %s: %w", note.newId, err))
+		}
+	}
+
+	// The layout is switched even when the config can't be written, the
+	// notes are moved already
+	if err := n.cfg.UpdateConfig("NotesLayout", to.Name()); err != nil {
+		errs = append(errs, fmt.Errorf("Couldn't save notes layout: %w", err))
+	}
+	saveMu.Unlock()
+
+	// Links to recordings in the frontmatter depend on the layout
+	for _, note := range c.notes {
+		if note.from.Root() != from.Root() {
+			continue
+		}
+
+		if err := refreshMetadata(note.newId); err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't refresh note %s: %w", note.newId, err))
+		}
+	}
+
+	if watching {
+		if err := n.Watch(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := n.SyncIndex(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if h != nil {
+		if _, err := h.repo.CommitAll(fmt.Sprintf("Convert notes to the %s layout", to.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return ids, errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package notes
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/henmalib/whisper-notes/backend/config"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+	"github.com/spf13/viper"
+)
+
+func setupTestNotes(t *testing.T, layout string) *Notes {
+	t.Helper()
+
+	dir := t.TempDir()
+	viper.Set("NotesPath", path.Join(dir, "notes"))
+	viper.Set("IndexPath", path.Join(dir, "index"))
+	viper.Set("NotesLayout", layout)
+	t.Cleanup(func() {
+		viper.Set("NotesLayout", LayoutFolders)
+	})
+
+	return &Notes{ctx: context.Background(), cfg: config.ConfigHelper{}}
+}
+
+type convertedFiles struct {
+	recordings int
+	revisions  int
+	attachment string
+	text       string
+}
+
+func readConvertedNote(t *testing.T, n *Notes, noteId string) convertedFiles {
+	t.Helper()
+
+	note := n.FindNote(noteId)
+	if note == nil {
+		t.Fatalf("Note %s wasn't found after conversion", noteId)
+	}
+
+	audio, err := note.ListAudio()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := convertedFiles{recordings: len(audio)}
+	if len(audio) > 0 {
+		revisions, err := note.ListRevisions(audio[0].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files.revisions = len(revisions)
+	}
+
+	attachment, err := os.ReadFile(path.Join(getLayout().AttachmentsDir(noteId), "diagram.png"))
+	if err != nil {
+		t.Fatalf("Attachment of note %s is missing: %v", noteId, err)
+	}
+	files.attachment = string(attachment)
+
+	if files.text, err = note.ReadData(); err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestConvertLayoutKeepsNoteFiles(t *testing.T) {
+	n := setupTestNotes(t, LayoutFolders)
+
+	noteId, err := n.CreateNoteFrom("Weekly sync", SourceTyped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note := n.FindNote(noteId)
+	if err := note.AddAudio([]byte("RIFF"), &transcript.Transcript{Text: "first take"}); err != nil {
+		t.Fatal(err)
+	}
+
+	audio, err := note.ListAudio()
+	if err != nil || len(audio) != 1 {
+		t.Fatalf("Expected one recording, got %d: %v", len(audio), err)
+	}
+
+	if err := note.ReplaceTranscript(audio[0].Id, &transcript.Transcript{Text: "second take"}); err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := getLayout().AttachmentsDir(noteId)
+	if err := os.MkdirAll(attachments, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(attachments, "diagram.png"), []byte("png"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := relativeLink(noteId, path.Join(attachments, "diagram.png"))
+	if _, err := UpdateNote(noteId, "![diagram]("+link+")\n", &Metadata{Title: "Weekly sync"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	before := readConvertedNote(t, n, noteId)
+	if before.revisions != 2 {
+		t.Fatalf("Expected 2 revisions before conversion, got %d", before.revisions)
+	}
+
+	for _, layout := range []string{LayoutVault, LayoutFolders} {
+		ids, err := n.ConvertLayout(layout)
+		// The test has no config file to save the layout into
+		if err != nil && !strings.Contains(err.Error(), "Couldn't save notes layout") {
+			t.Fatalf("Converting to %s: %v", layout, err)
+		}
+
+		noteId = ids[noteId]
+		after := readConvertedNote(t, n, noteId)
+
+		if after.recordings != before.recordings || after.revisions != before.revisions || after.attachment != before.attachment {
+			t.Errorf("Files changed converting to %s: before %+v, after %+v", layout, before, after)
+		}
+
+		wanted := relativeLink(noteId, path.Join(getLayout().AttachmentsDir(noteId), "diagram.png"))
+		if !strings.Contains(after.text, "("+wanted+")") {
+			t.Errorf("Link to attachment wasn't updated converting to %s: %q", layout, after.text)
+		}
+	}
+
+	if noteId == "" || !noteExists(noteId) {
+		t.Fatalf("Note %q is missing after converting back", noteId)
+	}
+}
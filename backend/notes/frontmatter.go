@@ -0,0 +1,251 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	noteFile = "note.md"
+	// Where metadata was kept before it moved into frontmatter
+	legacyMetadataFile = "_metadata.json"
+	frontmatterFence   = "---"
+)
+
+// Keys of frontmatter owned by Metadata, everything else belongs to other tools
+func metadataKeys() []string {
+	keys := []string{}
+	t := reflect.TypeOf(Metadata{})
+
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+
+	return keys
+}
+
+// Splits note.md into the frontmatter mapping and the body. Notes without
+// valid frontmatter return nil header and the whole content as the body
+func splitFrontmatter(content string) (*yaml.Node, string) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	firstLine, rest, found := strings.Cut(content, "\n")
+	if !found || strings.TrimRight(firstLine, "\r") != frontmatterFence {
+		return nil, content
+	}
+
+	header := ""
+	for {
+		line, next, found := strings.Cut(rest, "\n")
+		trimmed := strings.TrimRight(line, "\r")
+
+		if trimmed == frontmatterFence || trimmed == "..." {
+			rest = next
+			break
+		}
+
+		if !found {
+			// Never closed, so it's a horizontal rule and not frontmatter
+			return nil, content
+		}
+
+		header += line + "\n"
+		rest = next
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(header), &doc); err != nil {
+		// Text between two horizontal rules, keep it in the body so nothing is lost
+		return nil, content
+	}
+
+	// Empty frontmatter
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, rest
+	}
+
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, content
+	}
+
+	return doc.Content[0], rest
+}
+
+func joinFrontmatter(header *yaml.Node, body string) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString(frontmatterFence + "\n")
+
+	if header != nil && len(header.Content) > 0 {
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(header); err != nil {
+			return "", fmt.Errorf("Couldn't encode frontmatter: %w", err)
+		}
+		encoder.Close()
+	}
+
+	buf.WriteString(frontmatterFence + "\n")
+	buf.WriteString(body)
+
+	return buf.String(), nil
+}
+
+// Replaces keys owned by Metadata, keeping unknown keys and their order
+func mergeMetadata(header *yaml.Node, meta *Metadata) (*yaml.Node, error) {
+	var fresh yaml.Node
+	if err := fresh.Encode(meta); err != nil {
+		return nil, fmt.Errorf("Invalid metadata format: %w", err)
+	}
+
+	if header == nil {
+		return &fresh, nil
+	}
+
+	values := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		values[fresh.Content[i].Value] = fresh.Content[i+1]
+	}
+
+	owned := map[string]bool{}
+	for _, key := range metadataKeys() {
+		owned[key] = true
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: header.Style}
+	for i := 0; i+1 < len(header.Content); i += 2 {
+		key := header.Content[i]
+
+		if !owned[key.Value] {
+			merged.Content = append(merged.Content, key, header.Content[i+1])
+			continue
+		}
+
+		// Empty fields are omitted from fresh, so they get removed here
+		if value, ok := values[key.Value]; ok {
+			merged.Content = append(merged.Content, key, value)
+			delete(values, key.Value)
+		}
+	}
+
+	// New keys go after the existing ones, in struct order
+	for i := 0; i+1 < len(fresh.Content); i += 2 {
+		if value, ok := values[fresh.Content[i].Value]; ok {
+			merged.Content = append(merged.Content, fresh.Content[i], value)
+		}
+	}
+
+	return merged, nil
+}
+
+func readNoteFile(noteId string) (*yaml.Node, string, error) {
+	return readNoteFileAt(getNoteFile(noteId))
+}
+
+func readNoteFileAt(filePath string) (*yaml.Node, string, error) {
+	bytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	header, body := splitFrontmatter(string(bytes))
+
+	return header, body, nil
+}
+
+func writeNoteFile(noteId string, header *yaml.Node, body string) error {
+	content, err := joinFrontmatter(header, body)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(getNoteFile(noteId), []byte(content), 0600); err != nil {
+		return fmt.Errorf("Failed to write note file: %w", err)
+	}
+
+	// Every change of a note ends with writing note.md, recordings update
+	// the metadata. The note is saved even if indexing fails
+	if err := reindexNote(noteId); err != nil {
+		fmt.Println("Couldn't update search index:", err)
+	}
+
+	return nil
+}
+
+// Moves _metadata.json of the note into the frontmatter of note.md
+func migrateNoteMetadata(noteId string) error {
+	legacyPath := path.Join(getLayout().RecordingsDir(noteId), legacyMetadataFile)
+
+	bytes, err := os.ReadFile(legacyPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal(bytes, &meta); err != nil {
+		return fmt.Errorf("Invalid metadata of note %s: %w", noteId, err)
+	}
+
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	header, body, err := readNoteFile(noteId)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := meta.refresh(noteId, body); err != nil {
+		return err
+	}
+
+	header, err = mergeMetadata(header, &meta)
+	if err != nil {
+		return err
+	}
+
+	if err := writeNoteFile(noteId, header, body); err != nil {
+		return err
+	}
+
+	return os.Remove(legacyPath)
+}
+
+// Migrates every note still keeping metadata in _metadata.json, notes from
+// before dates and stats were kept get them filled in
+func (n *Notes) MigrateMetadata() error {
+	notes, err := n.ListNotes()
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, note := range notes {
+		if err := migrateNoteMetadata(note.Id); err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't migrate note %s: %w", note.Id, err))
+			continue
+		}
+
+		if note.Metadata != nil && !note.Metadata.Created.IsZero() {
+			continue
+		}
+
+		if err := refreshMetadata(note.Id); err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't fill metadata of note %s: %w", note.Id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,256 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/gitrepo"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// Never committed: the trash and temporary files of atomic saves
+var historyExcludes = []string{trashDir + "/", ".*.tmp"}
+
+// Git history of the notes directory, nil when GitHistory is off
+type noteHistory struct {
+	repo  *gitrepo.Repo
+	delay time.Duration
+
+	mu      sync.Mutex
+	pending map[string]bool
+	timer   *time.Timer
+}
+
+var (
+	historyMu sync.Mutex
+	history   *noteHistory
+)
+
+func getHistory() *noteHistory {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	return history
+}
+
+// Turns the notes directory into a git repository when GitHistory is on
+// and commits changes made while the app wasn't running
+func (n *Notes) SetupHistory() error {
+	cfg := n.cfg.GetConfig()
+	if !cfg.GitHistory {
+		return nil
+	}
+
+	if !gitrepo.Available() {
+		return fmt.Errorf("Git history is enabled, but git isn't installed")
+	}
+
+	if err := os.MkdirAll(cfg.NotesPath, 0755); err != nil {
+		return fmt.Errorf("Couldn't create notes directory: %w", err)
+	}
+
+	repo := gitrepo.NewRepo(cfg.NotesPath)
+	if err := repo.Init(); err != nil {
+		return err
+	}
+
+	excludes := historyExcludes
+	if cfg.GitExcludeAudio {
+		excludes = append(excludes, "*.wav")
+	}
+
+	if err := repo.SetExcludes(excludes); err != nil {
+		return err
+	}
+
+	if _, err := repo.CommitAll("Save changes made while the app was closed"); err != nil {
+		return err
+	}
+
+	h := &noteHistory{
+		repo:    repo,
+		delay:   time.Duration(max(cfg.GitCommitDelaySeconds, 0)) * time.Second,
+		pending: map[string]bool{},
+	}
+	h.timer = time.AfterFunc(h.delay, h.commit)
+	h.timer.Stop()
+
+	historyMu.Lock()
+	history = h
+	historyMu.Unlock()
+
+	return nil
+}
+
+// Commits the note once it stops changing for GitCommitDelaySeconds
+func scheduleCommit(noteId string) {
+	h := getHistory()
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending[noteId] = true
+	h.timer.Reset(h.delay)
+}
+
+func noteTitle(noteId string) string {
+	header, _, err := readNoteFile(noteId)
+	if err != nil {
+		return ""
+	}
+
+	meta, err := decodeMetadata(header)
+	if err != nil {
+		return ""
+	}
+
+	return meta.Title
+}
+
+func describeNote(noteId string) string {
+	if !noteExists(noteId) {
+		return fmt.Sprintf("removed note %s", noteId)
+	}
+
+	if title := noteTitle(noteId); title != "" {
+		return fmt.Sprintf("%q", title)
+	}
+
+	return fmt.Sprintf("note %s", noteId)
+}
+
+func commitMessage(noteIds []string) string {
+	sort.Strings(noteIds)
+
+	if len(noteIds) == 1 {
+		return fmt.Sprintf("Update %s\n\nNote: %s", describeNote(noteIds[0]), noteIds[0])
+	}
+
+	lines := []string{fmt.Sprintf("Update %d notes", len(noteIds)), ""}
+	for _, noteId := range noteIds {
+		lines = append(lines, fmt.Sprintf("- %s (%s)", describeNote(noteId), noteId))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (h *noteHistory) commit() {
+	h.mu.Lock()
+	h.timer.Stop()
+	noteIds := []string{}
+	for noteId := range h.pending {
+		noteIds = append(noteIds, noteId)
+	}
+	h.pending = map[string]bool{}
+	h.mu.Unlock()
+
+	if len(noteIds) == 0 {
+		return
+	}
+
+	if _, err := h.repo.CommitAll(commitMessage(noteIds)); err != nil {
+		fmt.Println("Couldn't commit notes:", err)
+	}
+}
+
+func requireHistory(noteId string) (*noteHistory, error) {
+	if err := validateNoteId(noteId); err != nil {
+		return nil, err
+	}
+
+	h := getHistory()
+	if h == nil {
+		return nil, errors.New("Git history of notes is disabled")
+	}
+
+	return h, nil
+}
+
+// Commits that changed the note, newest first. Limit of 0 returns all of them
+func (n *Notes) NoteHistory(noteId string, limit int) ([]gitrepo.Commit, error) {
+	h, err := requireHistory(noteId)
+	if err != nil {
+		return []gitrepo.Commit{}, err
+	}
+
+	return h.repo.Log(limit, getLayout().NotePaths(noteId)...)
+}
+
+// Text and metadata of the note at the commit, CurrentRevision reads the note as it is now
+func (n *Notes) ReadNoteVersion(noteId, commit string) (*NoteSnapshot, error) {
+	h, err := requireHistory(noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if commit == CurrentRevision {
+		content, err = os.ReadFile(getNoteFile(noteId))
+	} else {
+		// Layout without a root gives paths relative to the repository
+		content, err = h.repo.Show(commit, layoutAt("").NoteFile(noteId))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read note %s at %s: %w", noteId, commit, err)
+	}
+
+	header, body := splitFrontmatter(string(content))
+	meta, err := decodeMetadata(header)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid metadata of note %s at %s: %w", noteId, commit, err)
+	}
+
+	return &NoteSnapshot{Version: contentVersion(content), Text: body, Metadata: meta}, nil
+}
+
+// Word diff of the note text between two commits, either can be CurrentRevision
+func (n *Notes) DiffNoteVersions(noteId, fromCommit, toCommit string) ([]transcript.DiffOp, error) {
+	from, err := n.ReadNoteVersion(noteId, fromCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := n.ReadNoteVersion(noteId, toCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	return transcript.DiffWords(from.Text, to.Text), nil
+}
+
+// Brings the note back to how it was at the commit. Pending changes are
+// committed first, so the restore can be undone too
+func (n *Notes) RestoreNoteVersion(noteId, commit string) error {
+	h, err := requireHistory(noteId)
+	if err != nil {
+		return err
+	}
+
+	h.commit()
+
+	if err := h.repo.Restore(commit, getLayout().NotePaths(noteId)...); err != nil {
+		return fmt.Errorf("Couldn't restore note %s: %w", noteId, err)
+	}
+
+	// Recordings excluded from history may be gone, the derived fields follow the files
+	if err := refreshMetadata(noteId); err != nil {
+		return err
+	}
+
+	short := commit[:min(len(commit), 7)]
+	message := fmt.Sprintf("Restore %s from %s\n\nNote: %s", describeNote(noteId), short, noteId)
+	if _, err := h.repo.CommitAll(message); err != nil {
+		return fmt.Errorf("Couldn't commit restored note %s: %w", noteId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,519 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Folder of a note that imported attachments are copied into
+const attachmentsDir = "attachments"
+
+// Frontmatter keys other tools keep dates in, the first one set wins
+var (
+	createdKeys  = []string{"created", "date created", "created_at", "date"}
+	modifiedKeys = []string{"modified", "date modified", "updated", "updated_at"}
+)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+type ImportedNote struct {
+	// Markdown file relative to the imported folder
+	Path string `json:"path"`
+	// Empty in a dry run
+	NoteId      string    `json:"noteId,omitempty"`
+	Title       string    `json:"title"`
+	Tags        []string  `json:"tags"`
+	Created     time.Time `json:"created"`
+	Modified    time.Time `json:"modified"`
+	Attachments []string  `json:"attachments"`
+	// Links to other notes of the folder, rewritten to the imported ones
+	Links int `json:"links"`
+	// Links and embeds matching no file of the folder, kept as they were
+	Unresolved []string `json:"unresolved"`
+}
+
+type ImportReport struct {
+	Source string         `json:"source"`
+	DryRun bool           `json:"dryRun"`
+	Notes  []ImportedNote `json:"notes"`
+	// Files that are neither notes nor attached to one
+	Skipped []string `json:"skipped"`
+	Errors  []string `json:"errors"`
+}
+
+// Markdown files and everything else of the imported folder, by path
+// relative to it with forward slashes
+type importSource struct {
+	root  string
+	notes []string
+	files []string
+	// Lowercased paths, links don't have to match the case
+	paths map[string]string
+	// Lowercased file names, for links that don't give a folder
+	names map[string][]string
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+func scanImportSource(root string) (*importSource, error) {
+	source := &importSource{
+		root:  root,
+		paths: map[string]string{},
+		names: map[string][]string{},
+	}
+
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Settings of Obsidian, its trash and other tools
+		if strings.HasPrefix(entry.Name(), ".") && filePath != root {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if isMarkdown(rel) {
+			source.notes = append(source.notes, rel)
+		} else {
+			source.files = append(source.files, rel)
+		}
+
+		source.paths[strings.ToLower(rel)] = rel
+		name := strings.ToLower(path.Base(rel))
+		source.names[name] = append(source.names[name], rel)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read %s: %w", root, err)
+	}
+
+	return source, nil
+}
+
+// Finds the file a link points to the way Obsidian does: a path from the
+// linking note or from the root, otherwise the nearest file with that name.
+// Links without an extension point to notes
+func (s *importSource) resolve(from, target string) (string, bool) {
+	target = strings.TrimPrefix(path.Clean("/"+target), "/")
+	if target == "" {
+		return "", false
+	}
+
+	candidates := []string{target}
+	if path.Ext(target) == "" {
+		candidates = []string{target + ".md", target + ".markdown"}
+	}
+
+	for _, candidate := range candidates {
+		for _, full := range []string{path.Join(path.Dir(from), candidate), candidate} {
+			if rel, ok := s.paths[strings.ToLower(full)]; ok {
+				return rel, true
+			}
+		}
+	}
+
+	matches := []string{}
+	for _, candidate := range candidates {
+		for _, rel := range s.names[strings.ToLower(path.Base(candidate))] {
+			// A folder given in the link has to match the end of the path
+			if strings.HasSuffix(strings.ToLower("/"+rel), strings.ToLower("/"+candidate)) {
+				matches = append(matches, rel)
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	slices.SortFunc(matches, func(a, b string) int {
+		if sameA, sameB := path.Dir(a) == path.Dir(from), path.Dir(b) == path.Dir(from); sameA != sameB {
+			if sameA {
+				return -1
+			}
+			return 1
+		}
+
+		return strings.Count(a, "/") - strings.Count(b, "/")
+	})
+
+	return matches[0], true
+}
+
+func frontmatterFields(header *yaml.Node) map[string]any {
+	fields := map[string]any{}
+	if header != nil {
+		if err := header.Decode(&fields); err != nil {
+			fmt.Println("Couldn't read frontmatter:", err)
+		}
+	}
+
+	return fields
+}
+
+func fieldString(fields map[string]any, key string) string {
+	if value, ok := fields[key].(string); ok {
+		return strings.TrimSpace(value)
+	}
+
+	return ""
+}
+
+func fieldDate(fields map[string]any, keys []string) time.Time {
+	for _, key := range keys {
+		switch value := fields[key].(type) {
+		case time.Time:
+			return value
+		case string:
+			for _, layout := range dateLayouts {
+				if date, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local); err == nil {
+					return date
+				}
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// Tags of the frontmatter, as a list or a comma or space separated string
+func fieldTags(fields map[string]any) []string {
+	tags := []string{}
+
+	for _, key := range []string{"tags", "tag"} {
+		switch value := fields[key].(type) {
+		case string:
+			tags = append(tags, strings.FieldsFunc(value, func(r rune) bool {
+				return r == ',' || r == ' '
+			})...)
+		case []any:
+			for _, tag := range value {
+				if tag, ok := tag.(string); ok {
+					tags = append(tags, tag)
+				}
+			}
+		}
+	}
+
+	return tags
+}
+
+func firstHeading(body string) string {
+	title := ""
+	outsideCode(body, func(text string) string {
+		if title == "" {
+			if match := headingPattern.FindStringSubmatch(text); match != nil {
+				title = match[1]
+			}
+		}
+		return text
+	})
+
+	return title
+}
+
+// Tags written in the text like #project/alpha. Tags of digits only are
+// issue numbers and the like, Obsidian doesn't count them either
+func inlineTags(body string) []string {
+	tags := []string{}
+	outsideCode(body, func(text string) string {
+		for _, match := range inlineTagPattern.FindAllStringSubmatch(text, -1) {
+			if strings.Trim(match[1], "0123456789") != "" {
+				tags = append(tags, match[1])
+			}
+		}
+		return text
+	})
+
+	return tags
+}
+
+func uniqueTags(tags []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+
+		seen[strings.ToLower(tag)] = true
+		unique = append(unique, tag)
+	}
+
+	return unique
+}
+
+// Imports every Markdown file of the folder, like an Obsidian vault, as a
+// note. A dry run only reports what would be imported
+func (n *Notes) ImportMarkdown(folder string, dryRun bool) (*ImportReport, error) {
+	stat, err := os.Stat(folder)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't open %s: %w", folder, err)
+	}
+
+	if !stat.IsDir() {
+		return nil, fmt.Errorf("%s isn't a folder", folder)
+	}
+
+	source, err := scanImportSource(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{
+		Source:  folder,
+		DryRun:  dryRun,
+		Notes:   []ImportedNote{},
+		Skipped: []string{},
+		Errors:  []string{},
+	}
+
+	// Links between notes need ids of all of them up front
+	noteIds := map[string]string{}
+	for _, rel := range source.notes {
+		if dryRun {
+			noteIds[rel] = rel
+			continue
+		}
+
+		noteId, err := n.CreateNoteFrom(strings.TrimSuffix(path.Base(rel), path.Ext(rel)), SourceImported)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("Couldn't create note for %s: %s", rel, err))
+			continue
+		}
+
+		noteIds[rel] = noteId
+	}
+
+	attached := map[string]bool{}
+
+	for _, rel := range source.notes {
+		noteId, ok := noteIds[rel]
+		if !ok {
+			continue
+		}
+
+		imported, err := importNote(source, rel, noteId, noteIds, dryRun)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("Couldn't import %s: %s", rel, err))
+
+			// The note was created up front for links, those to it end up broken
+			if !dryRun {
+				if err := n.DeleteNote(noteId); err != nil {
+					fmt.Println("Couldn't remove note of failed import:", err)
+				}
+			}
+			continue
+		}
+
+		for _, attachment := range imported.Attachments {
+			attached[attachment] = true
+		}
+
+		if dryRun {
+			imported.NoteId = ""
+		}
+
+		report.Notes = append(report.Notes, *imported)
+	}
+
+	for _, rel := range source.files {
+		if !attached[rel] {
+			report.Skipped = append(report.Skipped, rel)
+		}
+	}
+
+	return report, nil
+}
+
+func importNote(source *importSource, rel, noteId string, noteIds map[string]string, dryRun bool) (*ImportedNote, error) {
+	filePath := filepath.Join(source.root, filepath.FromSlash(rel))
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	header, body := splitFrontmatter(strings.ReplaceAll(string(content), "\r\n", "\n"))
+	fields := frontmatterFields(header)
+
+	imported := &ImportedNote{
+		Path:        rel,
+		NoteId:      noteId,
+		Title:       fieldString(fields, "title"),
+		Tags:        uniqueTags(append(fieldTags(fields), inlineTags(body)...)),
+		Created:     fieldDate(fields, createdKeys),
+		Modified:    fieldDate(fields, modifiedKeys),
+		Attachments: []string{},
+		Unresolved:  []string{},
+	}
+
+	if imported.Title == "" {
+		imported.Title = firstHeading(body)
+	}
+
+	if imported.Title == "" {
+		imported.Title = strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	}
+
+	// Creation time isn't kept by every system, the oldest known one will do
+	if imported.Modified.IsZero() {
+		imported.Modified = stat.ModTime()
+	}
+
+	if imported.Created.IsZero() || imported.Created.After(imported.Modified) {
+		imported.Created = imported.Modified
+	}
+
+	links := &linkRewriter{
+		source:  source,
+		from:    rel,
+		noteId:  noteId,
+		noteIds: noteIds,
+		note:    imported,
+		files:   map[string]string{},
+		used:    map[string]bool{},
+	}
+	body = links.rewrite(body)
+
+	if dryRun {
+		return imported, nil
+	}
+
+	meta := &Metadata{
+		Title:    imported.Title,
+		Created:  imported.Created.Truncate(time.Second),
+		Tags:     imported.Tags,
+		Source:   SourceImported,
+		Language: fieldString(fields, "language"),
+	}
+
+	if err := writeImportedNote(source, noteId, header, body, meta, links.files); err != nil {
+		return imported, err
+	}
+
+	// Folders last, writing files into them changes their time
+	layout := getLayout()
+	changed := []string{layout.NoteFile(noteId)}
+	for _, notePath := range layout.NotePaths(noteId) {
+		changed = append(changed, path.Join(layout.Root(), notePath))
+	}
+
+	for _, changed := range slices.Compact(changed) {
+		if _, err := os.Stat(changed); err != nil {
+			continue
+		}
+
+		if err := os.Chtimes(changed, imported.Modified, imported.Modified); err != nil {
+			fmt.Println("Couldn't keep modification time of imported note:", err)
+		}
+	}
+
+	return imported, nil
+}
+
+// Copies attachments, by their name in the note, and writes note.md keeping
+// frontmatter keys of other tools
+func writeImportedNote(source *importSource, noteId string, header *yaml.Node, body string, meta *Metadata, files map[string]string) error {
+	attachmentsPath := getLayout().AttachmentsDir(noteId)
+
+	for name, rel := range files {
+		from := filepath.Join(source.root, filepath.FromSlash(rel))
+		if err := copyFile(from, path.Join(attachmentsPath, name)); err != nil {
+			return fmt.Errorf("Couldn't copy attachment %s: %w", rel, err)
+		}
+	}
+
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	if err := meta.refresh(noteId, body); err != nil {
+		return err
+	}
+
+	header, err := mergeMetadata(header, meta)
+	if err != nil {
+		return err
+	}
+
+	return writeNoteFile(noteId, header, body)
+}
+
+func copyFile(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(to), 0755); err != nil {
+		return err
+	}
+
+	target, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+
+	if err := target.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(to, stat.ModTime(), stat.ModTime()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("Couldn't keep modification time of attachment:", err)
+	}
+
+	return nil
+}
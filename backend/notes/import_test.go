@@ -0,0 +1,172 @@
+package notes
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Writes files of a folder to import, by their path relative to it
+func writeImportFolder(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for rel, content := range files {
+		filePath := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestImportRewritesLinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		want       string
+		unresolved []string
+		// Copied attachments by their name in the note
+		attachments map[string]string
+	}{
+		{
+			name: "wikilink to a note",
+			body: "See [[Target]]",
+			want: "See [Target](Target.md)",
+		},
+		{
+			name: "wikilink with a heading and a label",
+			body: "See [[Target#Next Steps|the plan]]",
+			want: "See [the plan](Target.md#next-steps)",
+		},
+		{
+			name: "link to a note in a folder by name only",
+			body: "See [[Deep Note]]",
+			want: "See [Deep Note](Deep%20Note.md)",
+		},
+		{
+			name: "Markdown link with an escaped path",
+			body: "See [deep](folder/Deep%20Note.md)",
+			want: "See [deep](Deep%20Note.md)",
+		},
+		{
+			name:        "embedded image",
+			body:        "![[diagram.png|300]]",
+			want:        "![diagram](attachments/Source/diagram.png)",
+			attachments: map[string]string{"diagram.png": "top"},
+		},
+		{
+			name:        "files with the same name from different folders",
+			body:        "![[diagram.png]] ![](sub/diagram.png)",
+			want:        "![diagram](attachments/Source/diagram.png) ![](attachments/Source/diagram%20%282%29.png)",
+			attachments: map[string]string{"diagram.png": "top", "diagram (2).png": "nested"},
+		},
+		{
+			name:       "missing note is kept",
+			body:       "See [[Missing]] and [x](gone.md)",
+			want:       "See [[Missing]] and [x](gone.md)",
+			unresolved: []string{"[[Missing]]", "[x](gone.md)"},
+		},
+		{
+			name: "web links and code are kept",
+			body: "[site](https://example.com) `[[Target]]`\n```\n[[Target]]\n```",
+			want: "[site](https://example.com) `[[Target]]`\n```\n[[Target]]\n```",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := setupTestNotes(t, LayoutVault)
+			root := writeImportFolder(t, map[string]string{
+				"Source.md":           test.body,
+				"Target.md":           "# Target\n\n## Next Steps",
+				"folder/Deep Note.md": "deep",
+				"diagram.png":         "top",
+				"sub/diagram.png":     "nested",
+			})
+
+			report, err := n.ImportMarkdown(root, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Errors) != 0 {
+				t.Fatalf("Import failed: %v", report.Errors)
+			}
+
+			i := slices.IndexFunc(report.Notes, func(note ImportedNote) bool { return note.Path == "Source.md" })
+			if i < 0 {
+				t.Fatalf("Source.md wasn't imported: %+v", report.Notes)
+			}
+
+			imported := report.Notes[i]
+			if !slices.Equal(imported.Unresolved, test.unresolved) {
+				t.Errorf("Expected unresolved %v, got %v", test.unresolved, imported.Unresolved)
+			}
+
+			text, err := n.FindNote(imported.NoteId).ReadData()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(text) != test.want {
+				t.Errorf("Expected %q, got %q", test.want, text)
+			}
+
+			if len(imported.Attachments) != len(test.attachments) {
+				t.Errorf("Expected %d attachments, got %v", len(test.attachments), imported.Attachments)
+			}
+
+			for name, want := range test.attachments {
+				content, err := os.ReadFile(filepath.Join(getLayout().AttachmentsDir(imported.NoteId), name))
+				if err != nil {
+					t.Fatalf("Attachment %s wasn't copied: %v", name, err)
+				}
+				if string(content) != want {
+					t.Errorf("Attachment %s has %q, expected %q", name, content, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDryRunWritesNothing(t *testing.T) {
+	n := setupTestNotes(t, LayoutVault)
+	root := writeImportFolder(t, map[string]string{
+		"Source.md":   "See [[Target]] ![[diagram.png]]",
+		"Target.md":   "target",
+		"diagram.png": "image",
+		"notes.txt":   "stray",
+	})
+
+	report, err := n.ImportMarkdown(root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Notes) != 2 || len(report.Errors) != 0 {
+		t.Fatalf("Expected two notes to import, got %+v", report)
+	}
+
+	for _, note := range report.Notes {
+		if note.NoteId != "" {
+			t.Errorf("Dry run returned note id %s", note.NoteId)
+		}
+	}
+
+	if !slices.Equal(report.Skipped, []string{"notes.txt"}) {
+		t.Errorf("Expected notes.txt to be skipped, got %v", report.Skipped)
+	}
+
+	noteIds, err := getLayout().NoteIds()
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(noteIds) != 0 {
+		t.Errorf("Dry run created notes %v", noteIds)
+	}
+}
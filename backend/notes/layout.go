@@ -0,0 +1,319 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+// Ways notes can be stored in NotesPath, see NotesLayout of the config
+const (
+	// <id>/note.md with recordings next to it, ids are UUIDs
+	LayoutFolders = "folders"
+	// <Title>.md with recordings in attachments/<Title>, so NotesPath can
+	// be opened as an Obsidian or Logseq vault
+	LayoutVault = "vault"
+)
+
+// Folder of the vault layout recordings and attachments of notes go into
+const vaultAttachmentsDir = "attachments"
+
+// Longest file name or note id made from a title, in characters
+const maxSlugLength = 100
+
+// Where files of notes live inside a root folder: NotesPath, or the trash
+// that keeps trashed notes the same way
+type Layout interface {
+	Name() string
+	Root() string
+	// Ids of the notes in the root, in no particular order
+	NoteIds() ([]string, error)
+	// Markdown file with the frontmatter and text of the note
+	NoteFile(noteId string) string
+	// Recordings with their transcripts and older versions
+	RecordingsDir(noteId string) string
+	// Files imported or linked into the note
+	AttachmentsDir(noteId string) string
+	// Files and folders of the note relative to the root, moving them moves
+	// the note. The first one exists for every note
+	NotePaths(noteId string) []string
+	// Note the path relative to the root belongs to, empty for other files
+	NoteOf(rel string) string
+	// Id for a new note with the title that no other note has
+	NewNoteId(title string) string
+	// Links to recordings for the frontmatter, so other tools reading the
+	// notes find them. Nil when the recordings are next to the note anyway
+	RecordingLinks(noteId string, recordingIds []string) []string
+}
+
+func newLayout(name, root string) (Layout, error) {
+	switch name {
+	case LayoutFolders, "":
+		return folderLayout{root}, nil
+	case LayoutVault:
+		return vaultLayout{root}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown notes layout %q", name)
+}
+
+func getNotesRoot() string {
+	return os.ExpandEnv(viper.GetString("NotesPath"))
+}
+
+// Layout of NotesPath from the config, unknown ones fall back to folders
+func getLayout() Layout {
+	return layoutAt(getNotesRoot())
+}
+
+func layoutAt(root string) Layout {
+	layout, err := newLayout(viper.GetString("NotesLayout"), root)
+	if err != nil {
+		fmt.Println("Error while picking notes layout:", err)
+		return folderLayout{root}
+	}
+
+	return layout
+}
+
+func getNoteFile(noteId string) string {
+	return getLayout().NoteFile(noteId)
+}
+
+// First of the note paths. It's a folder when the note file is inside it,
+// so other files in the root aren't taken for notes
+func noteStat(layout Layout, noteId string) (os.FileInfo, error) {
+	mainPath := path.Join(layout.Root(), layout.NotePaths(noteId)[0])
+
+	stat, err := os.Stat(mainPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if stat.IsDir() != strings.HasPrefix(layout.NoteFile(noteId), mainPath+"/") {
+		return nil, fmt.Errorf("%s isn't a note", mainPath)
+	}
+
+	return stat, nil
+}
+
+func noteExists(noteId string) bool {
+	_, err := noteStat(getLayout(), noteId)
+	return err == nil
+}
+
+// Link written into the text of one note to a file, relative to the note file
+func relativeLink(noteId, target string) string {
+	return relativeLinkFrom(getNoteFile(noteId), target)
+}
+
+func relativeLinkFrom(noteFile, target string) string {
+	from := strings.Split(path.Dir(noteFile), "/")
+	to := strings.Split(path.Clean(target), "/")
+
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+
+	parts := []string{}
+	for range from[common:] {
+		parts = append(parts, "..")
+	}
+
+	return escapeLinkPath(path.Join(append(parts, to[common:]...)...))
+}
+
+func readDirNames(dir string, keep func(os.DirEntry) bool) ([]string, error) {
+	names := []string{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return names, nil
+		}
+
+		return names, err
+	}
+
+	for _, entry := range entries {
+		// Dot files are the trash and folders of other tools
+		if !strings.HasPrefix(entry.Name(), ".") && keep(entry) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+type folderLayout struct {
+	root string
+}
+
+func (l folderLayout) Name() string {
+	return LayoutFolders
+}
+
+func (l folderLayout) Root() string {
+	return l.root
+}
+
+func (l folderLayout) NoteIds() ([]string, error) {
+	return readDirNames(l.root, os.DirEntry.IsDir)
+}
+
+func (l folderLayout) NoteFile(noteId string) string {
+	return path.Join(l.root, noteId, noteFile)
+}
+
+func (l folderLayout) RecordingsDir(noteId string) string {
+	return path.Join(l.root, noteId)
+}
+
+func (l folderLayout) AttachmentsDir(noteId string) string {
+	return path.Join(l.root, noteId, attachmentsDir)
+}
+
+func (l folderLayout) NotePaths(noteId string) []string {
+	return []string{noteId}
+}
+
+func (l folderLayout) NoteOf(rel string) string {
+	noteId, _, _ := strings.Cut(rel, "/")
+	if validateNoteId(noteId) != nil {
+		return ""
+	}
+
+	return noteId
+}
+
+func (l folderLayout) NewNoteId(title string) string {
+	return uuid.New().String()
+}
+
+func (l folderLayout) RecordingLinks(noteId string, recordingIds []string) []string {
+	return nil
+}
+
+type vaultLayout struct {
+	root string
+}
+
+func (l vaultLayout) Name() string {
+	return LayoutVault
+}
+
+func (l vaultLayout) Root() string {
+	return l.root
+}
+
+func (l vaultLayout) NoteIds() ([]string, error) {
+	names, err := readDirNames(l.root, func(entry os.DirEntry) bool {
+		return !entry.IsDir() && strings.EqualFold(path.Ext(entry.Name()), ".md")
+	})
+
+	for i, name := range names {
+		names[i] = strings.TrimSuffix(name, path.Ext(name))
+	}
+
+	return names, err
+}
+
+func (l vaultLayout) NoteFile(noteId string) string {
+	return path.Join(l.root, noteId+".md")
+}
+
+func (l vaultLayout) RecordingsDir(noteId string) string {
+	return path.Join(l.root, vaultAttachmentsDir, noteId)
+}
+
+func (l vaultLayout) AttachmentsDir(noteId string) string {
+	return l.RecordingsDir(noteId)
+}
+
+func (l vaultLayout) NotePaths(noteId string) []string {
+	return []string{noteId + ".md", path.Join(vaultAttachmentsDir, noteId)}
+}
+
+func (l vaultLayout) NoteOf(rel string) string {
+	parts := strings.Split(rel, "/")
+
+	switch {
+	case len(parts) == 1 && strings.EqualFold(path.Ext(rel), ".md"):
+		rel = strings.TrimSuffix(rel, path.Ext(rel))
+	case len(parts) >= 2 && parts[0] == vaultAttachmentsDir:
+		rel = parts[1]
+	default:
+		return ""
+	}
+
+	if validateNoteId(rel) != nil {
+		return ""
+	}
+
+	return rel
+}
+
+// File name from a note title, without characters that aren't allowed on
+// any of the supported systems or break wikilinks
+func FileName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*#^[]`, r) {
+			return ' '
+		}
+
+		return r
+	}, title)
+
+	name = strings.Join(strings.Fields(name), " ")
+	if runes := []rune(name); len(runes) > maxSlugLength {
+		name = strings.TrimSpace(string(runes[:maxSlugLength]))
+	}
+
+	// Windows drops trailing dots, leading ones hide the file elsewhere
+	name = strings.Trim(name, ". ")
+	if name == "" {
+		return "Untitled"
+	}
+
+	return name
+}
+
+// Title as a file name, a number is added when a note has it already
+func (l vaultLayout) NewNoteId(title string) string {
+	slug := FileName(title)
+	if strings.EqualFold(slug, vaultAttachmentsDir) {
+		slug = "Untitled"
+	}
+
+	noteId := slug
+	for i := 2; ; i++ {
+		taken := false
+		for _, notePath := range l.NotePaths(noteId) {
+			if _, err := os.Stat(path.Join(l.root, notePath)); err == nil {
+				taken = true
+			}
+		}
+
+		if !taken {
+			return noteId
+		}
+
+		noteId = fmt.Sprintf("%s %d", slug, i)
+	}
+}
+
+func (l vaultLayout) RecordingLinks(noteId string, recordingIds []string) []string {
+	links := []string{}
+	for _, recordingId := range recordingIds {
+		links = append(links, fmt.Sprintf("[[%s/%s/%s.wav]]", vaultAttachmentsDir, noteId, recordingId))
+	}
+
+	return links
+}
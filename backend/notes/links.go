@@ -0,0 +1,257 @@
+package notes
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var (
+	// [[Note]], [[folder/Note#Heading|Label]] and ![[image.png|300]]
+	wikiLinkPattern = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+	// [Label](path/to/file.md "Title") and ![alt](<image with spaces.png>)
+	markdownLinkPattern = regexp.MustCompile(`(!?)\[([^\]\n]*)\]\((<[^>\n]+>|[^()\s]+)(\s+"[^"\n]*")?\)`)
+	// Either of them, so text written for one link isn't read as another
+	linkPattern      = regexp.MustCompile(wikiLinkPattern.String() + "|" + markdownLinkPattern.String())
+	inlineTagPattern = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]+)`)
+	headingPattern   = regexp.MustCompile(`(?m)^#{1,6}[ \t]+(.+?)[ \t#]*$`)
+	urlSchemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	// Width of embedded images, like 300 or 300x200
+	imageSizePattern = regexp.MustCompile(`^\d+(x\d+)?$`)
+)
+
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg", ".bmp", ".avif"}
+
+// Applies fn to the parts of Markdown outside fenced code blocks and inline
+// code, links and tags in code are examples and not links
+func outsideCode(text string, fn func(string) string) string {
+	var b, chunk strings.Builder
+	fence := ""
+
+	flush := func() {
+		parts := strings.Split(chunk.String(), "`")
+		for i := range parts {
+			// Even parts are outside of code spans, an unclosed backtick is just a character
+			if i%2 == 0 || (i == len(parts)-1 && len(parts)%2 == 0) {
+				parts[i] = fn(parts[i])
+			}
+		}
+
+		b.WriteString(strings.Join(parts, "`"))
+		chunk.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			flush()
+			fence = trimmed[:3]
+			b.WriteString(line)
+			continue
+		}
+
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+
+			b.WriteString(line)
+			continue
+		}
+
+		chunk.WriteString(line)
+	}
+
+	flush()
+
+	return b.String()
+}
+
+// Anchor of a heading the way Markdown renderers make them
+func headingAnchor(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(heading)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+
+	return b.String()
+}
+
+func escapeLinkPath(linkPath string) string {
+	return (&url.URL{Path: linkPath}).EscapedPath()
+}
+
+// Link from one note to another, relative so it works in any Markdown editor
+// opened on the notes directory
+func noteLink(fromId, toId string) string {
+	if fromId == toId {
+		return ""
+	}
+
+	return relativeLink(fromId, getNoteFile(toId))
+}
+
+// Rewrites links of an imported note to the notes and attachments they
+// point to, collecting attachments to copy on the way
+type linkRewriter struct {
+	source  *importSource
+	from    string
+	noteId  string
+	noteIds map[string]string
+	note    *ImportedNote
+	// Files to copy into attachmentsDir, by their name there
+	files map[string]string
+	// Names given to files already, lowercased
+	used map[string]bool
+}
+
+func (l *linkRewriter) rewrite(body string) string {
+	return outsideCode(body, func(text string) string {
+		return linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+			if strings.HasPrefix(strings.TrimPrefix(match, "!"), "[[") {
+				return l.wikiLink(match)
+			}
+
+			return l.markdownLink(match)
+		})
+	})
+}
+
+// Name of the file inside attachmentsDir, files with the same name from
+// different folders get a number
+func (l *linkRewriter) attach(rel string) string {
+	for name, attached := range l.files {
+		if attached == rel {
+			return name
+		}
+	}
+
+	base := path.Base(rel)
+	ext := path.Ext(base)
+	name := base
+	for i := 2; l.used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+
+	l.used[strings.ToLower(name)] = true
+	l.files[name] = rel
+	l.note.Attachments = append(l.note.Attachments, rel)
+
+	return name
+}
+
+func (l *linkRewriter) unresolved(link string) string {
+	if !slices.Contains(l.note.Unresolved, link) {
+		l.note.Unresolved = append(l.note.Unresolved, link)
+	}
+
+	return link
+}
+
+// Markdown link to the resolved file, fragment is a heading of a note
+func (l *linkRewriter) link(rel, fragment, label string, embed bool) (string, bool) {
+	if isMarkdown(rel) {
+		noteId, ok := l.noteIds[rel]
+		if !ok {
+			return "", false
+		}
+
+		target := noteLink(l.noteId, noteId)
+		// Block references have no equivalent, the link goes to the note
+		if fragment != "" && !strings.HasPrefix(fragment, "^") {
+			target += "#" + headingAnchor(fragment)
+		}
+
+		l.note.Links++
+		if target == "" {
+			return label, true
+		}
+
+		return fmt.Sprintf("[%s](%s)", label, target), true
+	}
+
+	target := relativeLink(l.noteId, path.Join(getLayout().AttachmentsDir(l.noteId), l.attach(rel)))
+	if embed && slices.Contains(imageExtensions, strings.ToLower(path.Ext(rel))) {
+		return fmt.Sprintf("![%s](%s)", label, target), true
+	}
+
+	return fmt.Sprintf("[%s](%s)", label, target), true
+}
+
+func (l *linkRewriter) wikiLink(match string) string {
+	parts := wikiLinkPattern.FindStringSubmatch(match)
+	embed := parts[1] == "!"
+
+	target, label, _ := strings.Cut(parts[2], "|")
+	// Links in tables escape the pipe
+	target = strings.TrimSuffix(target, `\`)
+	target, fragment, _ := strings.Cut(target, "#")
+	target = strings.TrimSpace(target)
+
+	if target == "" {
+		// Link to a heading of the same note
+		if fragment == "" {
+			return match
+		}
+
+		return fmt.Sprintf("[%s](#%s)", fragment, headingAnchor(fragment))
+	}
+
+	rel, ok := l.source.resolve(l.from, target)
+	if !ok {
+		return l.unresolved(match)
+	}
+
+	if label == "" || imageSizePattern.MatchString(label) {
+		label = strings.TrimSuffix(path.Base(target), path.Ext(target))
+		if fragment != "" && !strings.HasPrefix(fragment, "^") {
+			label += " > " + fragment
+		}
+	}
+
+	link, ok := l.link(rel, fragment, label, embed)
+	if !ok {
+		return l.unresolved(match)
+	}
+
+	return link
+}
+
+func (l *linkRewriter) markdownLink(match string) string {
+	parts := markdownLinkPattern.FindStringSubmatch(match)
+	embed := parts[1] == "!"
+	label := parts[2]
+	target := strings.TrimSuffix(strings.TrimPrefix(parts[3], "<"), ">")
+
+	// Web links and anchors within the note stay as they are
+	if urlSchemePattern.MatchString(target) || strings.HasPrefix(target, "#") {
+		return match
+	}
+
+	target, fragment, _ := strings.Cut(target, "#")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	rel, ok := l.source.resolve(l.from, target)
+	if !ok {
+		return l.unresolved(match)
+	}
+
+	link, ok := l.link(rel, fragment, label, embed)
+	if !ok {
+		return l.unresolved(match)
+	}
+
+	return link
+}
@@ -0,0 +1,199 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/henmalib/whisper-notes/backend/wav"
+	"go.yaml.in/yaml/v3"
+)
+
+func decodeMetadata(header *yaml.Node) (*Metadata, error) {
+	var metadata Metadata
+	if header != nil {
+		if err := header.Decode(&metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return &metadata, nil
+}
+
+// Words with at least one letter or digit, so markdown markup isn't counted
+func countWords(text string) int {
+	count := 0
+	for _, field := range strings.Fields(text) {
+		if strings.IndexFunc(field, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsNumber(r)
+		}) >= 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Length of the WAV recording
+func RecordingDuration(audioPath string) (time.Duration, error) {
+	file, err := os.Open(audioPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return wav.Duration(file)
+}
+
+// Recomputes the fields derived from note files: duration, word count,
+// language and model. Notes made before these fields existed get created
+// date and source guessed from their files
+func (m *Metadata) refresh(noteId string, body string) error {
+	layout := getLayout()
+	notePath := layout.RecordingsDir(noteId)
+
+	files, err := os.ReadDir(notePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Couldn't read note %s: %w", noteId, err)
+	}
+
+	var duration time.Duration
+	words := countWords(body)
+	created := time.Time{}
+	recordingIds := []string{}
+
+	for _, file := range files {
+		filename := file.Name()
+		if file.IsDir() || !strings.HasSuffix(filename, ".wav") {
+			continue
+		}
+
+		audioPath := path.Join(notePath, filename)
+		id := strings.TrimSuffix(filename, ".wav")
+		recordingIds = append(recordingIds, id)
+
+		if seconds, err := strconv.ParseInt(id, 10, 64); err == nil {
+			if date := time.Unix(seconds, 0); created.IsZero() || date.Before(created) {
+				created = date
+			}
+		}
+
+		if length, err := RecordingDuration(audioPath); err == nil {
+			duration += length
+		} else {
+			fmt.Println("Couldn't get recording duration:", err)
+		}
+
+		if text, err := os.ReadFile(path.Join(notePath, id+".txt")); err == nil {
+			words += countWords(string(text))
+		}
+
+		// Recordings are named by unix time, so the last one is the latest
+		result, err := readTranscript(audioPath)
+		if err != nil {
+			fmt.Println("Error while reading transcript", err)
+		}
+
+		if result != nil {
+			m.Language = result.Language
+			m.Model = result.Model
+		}
+	}
+
+	m.Duration = duration.Round(time.Millisecond).Seconds()
+	m.WordCount = words
+	m.Recordings = layout.RecordingLinks(noteId, recordingIds)
+
+	if m.Created.IsZero() {
+		if stat, err := os.Stat(layout.NoteFile(noteId)); err == nil && (created.IsZero() || stat.ModTime().Before(created)) {
+			created = stat.ModTime()
+		}
+		m.Created = created.Truncate(time.Second)
+	}
+
+	if m.Source == "" {
+		m.Source = SourceTyped
+		if len(recordingIds) > 0 {
+			m.Source = SourceRecorded
+		}
+	}
+
+	return nil
+}
+
+// Brings derived metadata of the note in line with its files. note.md is
+// left alone when nothing changed, so its version stays the one the editor
+// is based on
+func refreshMetadata(noteId string) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	content, err := os.ReadFile(getNoteFile(noteId))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	header, body := splitFrontmatter(string(content))
+	if content == nil {
+		header, body = nil, ""
+	}
+
+	meta, err := decodeMetadata(header)
+	if err != nil {
+		return fmt.Errorf("Invalid metadata of note %s: %w", noteId, err)
+	}
+
+	if err := meta.refresh(noteId, body); err != nil {
+		return err
+	}
+
+	header, err = mergeMetadata(header, meta)
+	if err != nil {
+		return err
+	}
+
+	if content != nil {
+		if refreshed, err := joinFrontmatter(header, body); err == nil && refreshed == string(content) {
+			return nil
+		}
+	}
+
+	return writeNoteFile(noteId, header, body)
+}
+
+// Size of every file of the note, including stored versions
+func noteSize(noteId string) int64 {
+	layout := getLayout()
+
+	var size int64
+	for _, notePath := range layout.NotePaths(noteId) {
+		size += dirSize(path.Join(layout.Root(), notePath))
+	}
+
+	return size
+}
+
+func dirSize(dirPath string) int64 {
+	var size int64
+
+	filepath.WalkDir(dirPath, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
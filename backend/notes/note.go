@@ -2,66 +2,213 @@ package notes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"strings"
 	"time"
+
+	"github.com/henmalib/whisper-notes/backend/transcript"
 )
 
 type NoteInfo struct {
 	Id         string    `json:"id"`
 	ModifyDate time.Time `json:"ModifyDate"`
-	Size       int64     `json:"size"`
+	// Total size of the note files in bytes
+	Size     int64     `json:"size"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Changes with every save, passed back to UpdateNote to detect conflicts
+	Version string `json:"version"`
 }
 
+// How the note came to be
+const (
+	SourceRecorded = "recorded"
+	SourceImported = "imported"
+	SourceTyped    = "typed"
+)
+
+// Kept in the frontmatter of note.md
 type Metadata struct {
-	Title string `json:"title"`
+	Title   string    `json:"title" yaml:"title"`
+	Created time.Time `json:"created" yaml:"created,omitempty"`
+	Tags    []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Source  string    `json:"source,omitempty" yaml:"source,omitempty"`
+	// Language and model of the latest transcribed recording
+	Language string `json:"language,omitempty" yaml:"language,omitempty"`
+	Model    string `json:"model,omitempty" yaml:"model,omitempty"`
+	// Length of all recordings in seconds
+	Duration  float64 `json:"duration,omitempty" yaml:"duration,omitempty"`
+	WordCount int     `json:"wordCount,omitempty" yaml:"words,omitempty"`
+	// Names given to detected speakers, keyed by speaker number
+	Speakers map[int]string `json:"speakers,omitempty" yaml:"speakers,omitempty"`
+	// Links to the recordings for other tools, set by layouts that keep
+	// recordings away from the note
+	Recordings []string `json:"recordings,omitempty" yaml:"recordings,omitempty"`
+}
+
+func (m *Metadata) SpeakerLabel(speaker int) string {
+	if label, ok := m.Speakers[speaker]; ok && label != "" {
+		return label
+	}
+
+	return fmt.Sprintf("Speaker %d", speaker)
 }
 
+// Folder with recordings of the note
 func (n *NoteInfo) getPath() string {
-	return getNotePath(n.Id)
+	return getLayout().RecordingsDir(n.Id)
 }
 
+// Body of note.md without the frontmatter
 func (n *NoteInfo) ReadData() (string, error) {
-	path := path.Join(getNotePath(n.Id), "note.md")
-
-	bytes, err := os.ReadFile(path)
+	_, body, err := readNoteFile(n.Id)
 	if err != nil {
 		return "", fmt.Errorf("Error while reading note data: %w", err)
 	}
 
-	return string(bytes), nil
-
+	return body, nil
 }
 
 func (n *NoteInfo) ReadMetadata() (*Metadata, error) {
-	path := path.Join(n.getPath(), "_metadata.json")
+	// Notes that weren't migrated on startup, e.g. copied in afterwards
+	if err := migrateNoteMetadata(n.Id); err != nil {
+		fmt.Println("Couldn't migrate note metadata:", err)
+	}
 
-	bytes, err := os.ReadFile(path)
+	header, _, err := readNoteFile(n.Id)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't read note %s metadata: %w", n.Id, err)
 	}
 
-	var metadata Metadata
-	err = json.Unmarshal(bytes, &metadata)
+	metadata, err := decodeMetadata(header)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid metadata of note %s: %w", n.Id, err)
+	}
+
+	return metadata, nil
+}
+
+func (n *NoteInfo) AddAudio(audioBytes []byte, result *transcript.Transcript) error {
+	if err := os.MkdirAll(n.getPath(), 0755); err != nil {
+		return err
+	}
+
+	recordingPath := path.Join(n.getPath(), fmt.Sprint(time.Now().Unix()))
+
+	if err := writeFileAtomic(recordingPath+".wav", audioBytes, 0600); err != nil {
+		return err
+	}
+
+	if err := writeTranscript(recordingPath, result); err != nil {
+		return err
+	}
+
+	return refreshMetadata(n.Id)
+}
+
+// Clean text goes to the .txt file shown in the note, the .json keeps both
+func writeTranscript(recordingPath string, result *transcript.Transcript) error {
+	if err := writeFileAtomic(recordingPath+".txt", []byte(result.DisplayText()), 0600); err != nil {
+		return err
+	}
+
+	transcriptBytes, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("Invalid transcript format: %w", err)
+	}
+
+	return writeFileAtomic(recordingPath+".json", transcriptBytes, 0600)
+}
+
+func (n *NoteInfo) ReadAudio(recordingId string) ([]byte, error) {
+	recordingPath, err := n.getRecordingPath(recordingId)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := os.ReadFile(recordingPath + ".wav")
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read recording %s: %w", recordingId, err)
+	}
 
-	return &metadata, err
+	return bytes, nil
 }
 
-func (n *NoteInfo) AddAudio(audioBytes []byte, text string) error {
-	now := time.Now()
+// Saves a new transcript of the recording, the current one becomes an older version
+func (n *NoteInfo) ReplaceTranscript(recordingId string, result *transcript.Transcript) error {
+	recordingPath, err := n.getRecordingPath(recordingId)
+	if err != nil {
+		return err
+	}
 
-	if err := os.WriteFile(path.Join(n.getPath(), fmt.Sprintf("%d.wav", now.Unix())), audioBytes, 0600); err != nil {
+	if _, err := os.Stat(recordingPath + ".wav"); err != nil {
+		return fmt.Errorf("Recording %s doesn't exist: %w", recordingId, err)
+	}
+
+	if err := n.archiveTranscript(recordingId); err != nil {
+		return fmt.Errorf("Couldn't keep the previous transcript: %w", err)
+	}
+
+	if err := writeTranscript(recordingPath, result); err != nil {
 		return err
 	}
 
-	return os.WriteFile(path.Join(n.getPath(), fmt.Sprintf("%d.txt", now.Unix())), []byte(text), 0600)
+	return refreshMetadata(n.Id)
+}
+
+func (n *NoteInfo) getRecordingPath(recordingId string) (string, error) {
+	if recordingId == "" || strings.ContainsAny(recordingId, `/\.`) {
+		return "", fmt.Errorf("Invalid recording id %q", recordingId)
+	}
+
+	return path.Join(n.getPath(), recordingId), nil
+}
+
+func (n *NoteInfo) ReadTranscript(recordingId string) (*transcript.Transcript, error) {
+	recordingPath, err := n.getRecordingPath(recordingId)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := readTranscript(recordingPath + ".wav")
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read transcript of %s: %w", recordingId, err)
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("Recording %s has no stored transcript", recordingId)
+	}
+
+	return result, nil
 }
 
 type AudioFile struct {
-	AudioPath string `json:"audioPath"`
-	Text      string `json:"text"`
+	// Recording file name without extension
+	Id         string                 `json:"id"`
+	AudioPath  string                 `json:"audioPath"`
+	Text       string                 `json:"text"`
+	Transcript *transcript.Transcript `json:"transcript,omitempty"`
+}
+
+// Recordings made before transcripts were stored only have the .txt file
+func readTranscript(audioPath string) (*transcript.Transcript, error) {
+	bytes, err := os.ReadFile(strings.TrimSuffix(audioPath, ".wav") + ".json")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result transcript.Transcript
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		return nil, fmt.Errorf("Invalid transcript file: %w", err)
+	}
+
+	return &result, nil
 }
 
 func (n *NoteInfo) ListAudio() ([]AudioFile, error) {
@@ -70,6 +217,11 @@ func (n *NoteInfo) ListAudio() ([]AudioFile, error) {
 
 	files, err := os.ReadDir(notePath)
 	if err != nil {
+		// Notes of the vault layout get the folder with their first recording
+		if errors.Is(err, os.ErrNotExist) {
+			return audios, nil
+		}
+
 		return audios, err
 	}
 
@@ -83,12 +235,85 @@ func (n *NoteInfo) ListAudio() ([]AudioFile, error) {
 				continue
 			}
 
+			audioPath := path.Join(notePath, filename)
+
+			result, err := readTranscript(audioPath)
+			if err != nil {
+				fmt.Println("Error while reading transcript", err)
+			}
+
 			audios = append(audios, AudioFile{
-				AudioPath: path.Join(notePath, filename),
-				Text:      string(text),
+				Id:         strings.TrimSuffix(filename, ".wav"),
+				AudioPath:  audioPath,
+				Text:       string(text),
+				Transcript: result,
 			})
 		}
 	}
 
 	return audios, nil
 }
+
+func (n *NoteInfo) SetSpeakerLabel(speaker int, label string) error {
+	meta, err := n.ReadMetadata()
+	if err != nil {
+		return err
+	}
+
+	if meta.Speakers == nil {
+		meta.Speakers = map[int]string{}
+	}
+
+	if label == "" {
+		delete(meta.Speakers, speaker)
+	} else {
+		meta.Speakers[speaker] = label
+	}
+
+	return updateNoteMeta(n.Id, meta)
+}
+
+type DialogueLine struct {
+	Speaker int    `json:"speaker"`
+	Label   string `json:"label"`
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Text    string `json:"text"`
+}
+
+// Joins consecutive segments of the same speaker into lines
+func (n *NoteInfo) GetDialogue(recordingId string) ([]DialogueLine, error) {
+	lines := []DialogueLine{}
+
+	result, err := n.ReadTranscript(recordingId)
+	if err != nil {
+		return lines, err
+	}
+
+	meta, err := n.ReadMetadata()
+	if err != nil {
+		return lines, err
+	}
+
+	for _, segment := range result.Segments {
+		if segment.Speaker == 0 {
+			continue
+		}
+
+		if last := len(lines) - 1; last >= 0 && lines[last].Speaker == segment.Speaker {
+			lines[last].Text += " " + segment.Text
+			lines[last].End = segment.End
+			continue
+		}
+
+		lines = append(lines, DialogueLine{
+			Speaker: segment.Speaker,
+			Label:   meta.SpeakerLabel(segment.Speaker),
+			Start:   segment.Start,
+			End:     segment.End,
+			Text:    segment.Text,
+		})
+	}
+
+	return lines, nil
+}
@@ -2,15 +2,14 @@ package notes
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"sort"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/henmalib/whisper-notes/backend/config"
-	"github.com/spf13/viper"
 )
 
 type Notes struct {
@@ -32,27 +31,40 @@ func (n *Notes) ListNotes() ([]NoteInfo, error) {
 		return notes, fmt.Errorf("Couldn't create notes directory: %w", err)
 	}
 
-	files, err := os.ReadDir(n.cfg.GetConfig().NotesPath)
+	layout := getLayout()
+
+	noteIds, err := layout.NoteIds()
 	if err != nil {
 		return notes, fmt.Errorf("Couldn't read notes dir: %w", err)
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			// TODO: what should we do with erorrs here?
-			info, err := file.Info()
+	for _, noteId := range noteIds {
+		if validateNoteId(noteId) != nil {
+			continue
+		}
+
+		// TODO: what should we do with erorrs here?
+		info, err := noteStat(layout, noteId)
+		if err != nil {
+			fmt.Println("Error while getting note info:", err)
+			continue
+		}
+
+		note := NoteInfo{
+			Id:         noteId,
+			Size:       noteSize(noteId),
+			ModifyDate: info.ModTime(),
+		}
 
-			if err != nil {
-				fmt.Println("Error while getting note info: %w", err)
-				continue
-			}
+		if note.Metadata, err = note.ReadMetadata(); err != nil {
+			fmt.Println("Error while reading note metadata:", err)
+		}
 
-			notes = append(notes, NoteInfo{
-				Id:         info.Name(),
-				Size:       info.Size(),
-				ModifyDate: info.ModTime(),
-			})
+		if note.Version, err = noteVersion(note.Id); err != nil {
+			fmt.Println("Error while reading note version:", err)
 		}
+
+		notes = append(notes, note)
 	}
 
 	sort.Slice(notes, func(i, j int) bool {
@@ -62,90 +74,136 @@ func (n *Notes) ListNotes() ([]NoteInfo, error) {
 	return notes, nil
 }
 func (n *Notes) FindNote(id string) *NoteInfo {
-	notePath := path.Join(n.cfg.GetConfig().NotesPath, id)
+	if validateNoteId(id) != nil {
+		return nil
+	}
 
-	stat, err := os.Stat(notePath)
+	stat, err := noteStat(getLayout(), id)
 	if err != nil {
 		return nil
 	}
 
-	return &NoteInfo{
+	note := &NoteInfo{
 		Id:         id,
-		Size:       stat.Size(),
+		Size:       noteSize(id),
 		ModifyDate: stat.ModTime(),
 	}
-}
-
-func updateNoteMeta(noteId string, meta *Metadata) error {
-	notePath := getNotePath(noteId)
 
-	// TODO: instead of json, use frontmatter of .md
-	file, err := os.OpenFile(path.Join(notePath, "_metadata.json"), os.O_RDWR|os.O_CREATE, 0700)
+	if note.Metadata, err = note.ReadMetadata(); err != nil {
+		fmt.Println("Error while reading note metadata:", err)
+	}
 
-	if err != nil {
-		return fmt.Errorf("Coudn't create metadata file: %w", err)
+	if note.Version, err = noteVersion(id); err != nil {
+		fmt.Println("Error while reading note version:", err)
 	}
 
-	defer file.Close()
+	return note
+}
 
-	metadataBytes, err := json.Marshal(meta)
-	if err != nil {
-		return fmt.Errorf("Invalid metadata format: %w", err)
+func updateNoteMeta(noteId string, meta *Metadata) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	header, body, err := readNoteFile(noteId)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
 	}
 
-	_, err = file.Write(metadataBytes)
+	header, err = mergeMetadata(header, meta)
 	if err != nil {
-		return fmt.Errorf("Coudn't write metadata file: %w", err)
+		return err
 	}
 
-	return nil
+	return writeNoteFile(noteId, header, body)
 }
 
 func updateNoteData(noteId, data string) error {
-	notePath := getNotePath(noteId)
-	file, err := os.OpenFile(path.Join(notePath, "note.md"), os.O_RDWR|os.O_CREATE, 0700)
-
-	if err != nil {
-		return fmt.Errorf("Failed to create note file: %w", err)
-	}
+	saveMu.Lock()
+	defer saveMu.Unlock()
 
-	defer file.Close()
-
-	_, err = file.WriteString(data)
-	if err != nil {
-		return fmt.Errorf("Failed to write note file: %w", err)
+	header, _, err := readNoteFile(noteId)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
 	}
 
-	return nil
+	return writeNoteFile(noteId, header, data)
 }
 
 func (n *Notes) CreateNote(title string) (string, error) {
-	noteId := uuid.New().String()
-	notePath := getNotePath(noteId)
+	return n.CreateNoteFrom("Untitled", SourceTyped)
+}
+
+// Creates an empty note, source tells where its content comes from
+func (n *Notes) CreateNoteFrom(title string, source string) (string, error) {
+	layout := getLayout()
+	noteId := layout.NewNoteId(title)
 
-	if err := os.MkdirAll(notePath, 0755); err != nil {
+	if err := os.MkdirAll(path.Dir(layout.NoteFile(noteId)), 0755); err != nil {
 		return noteId, err
 	}
 
 	err := updateNoteMeta(noteId, &Metadata{
-		Title: "Untitled",
+		Title:   title,
+		Created: time.Now().Truncate(time.Second),
+		Source:  source,
 	})
 
 	return noteId, err
 }
 
-func getNotePath(noteId string) string {
-	return path.Join(os.ExpandEnv(viper.GetString("NotesPath")), noteId)
-}
+// Saves text and the fields edited by the user, the rest of metadata is
+// kept up to date by the notes themselves. With baseVersion, the version the
+// editor loaded, the save fails with ConflictError when the note was changed
+// since. Returns the new version
+func UpdateNote(noteId string, text string, meta *Metadata, baseVersion string) (string, error) {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+
+	content, err := os.ReadFile(getNoteFile(noteId))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
 
-func UpdateNote(noteId string, text string, meta *Metadata) error {
-	if err := updateNoteMeta(noteId, meta); err != nil {
-		return err
+	header, body := splitFrontmatter(string(content))
+	if content == nil {
+		header, body = nil, ""
 	}
 
-	if err := updateNoteData(noteId, text); err != nil {
-		return err
+	stored, err := decodeMetadata(header)
+	if err != nil {
+		return "", fmt.Errorf("Invalid metadata of note %s: %w", noteId, err)
+	}
+
+	if current := contentVersion(content); baseVersion != "" && (content == nil || current != baseVersion) {
+		theirs := NoteSnapshot{Text: body, Metadata: stored}
+		if content != nil {
+			theirs.Version = current
+		}
+
+		return "", &ConflictError{
+			NoteId: noteId,
+			Mine:   NoteSnapshot{Text: text, Metadata: meta},
+			Theirs: theirs,
+		}
+	}
+
+	updated := *stored
+	updated.Title = meta.Title
+	updated.Tags = meta.Tags
+	updated.Speakers = meta.Speakers
+
+	if err := updated.refresh(noteId, text); err != nil {
+		return "", err
+	}
+
+	header, err = mergeMetadata(header, &updated)
+	if err != nil {
+		return "", err
+	}
+
+	if err := writeNoteFile(noteId, header, text); err != nil {
+		return "", err
 	}
 
-	return nil
+	return noteVersion(noteId)
 }
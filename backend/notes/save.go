@@ -0,0 +1,103 @@
+package notes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Serializes read-modify-write of note files, so a save can't slip in
+// between the conflict check and the write of another one
+var saveMu sync.Mutex
+
+// Writes into a temporary file next to the target and renames it over,
+// so a crash leaves either the old or the new file, never a mix of both
+func writeFileAtomic(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return cleanup(err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return cleanup(err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Makes the rename itself durable. Directories can't be synced on
+	// Windows, the rename is durable there already
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
+
+func contentVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Hash of note.md, changes with every change of the text or metadata.
+// Empty when the note has no file yet
+func noteVersion(noteId string) (string, error) {
+	content, err := os.ReadFile(getNoteFile(noteId))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return contentVersion(content), nil
+}
+
+type NoteSnapshot struct {
+	// Empty for the version that wasn't saved
+	Version  string    `json:"version,omitempty"`
+	Text     string    `json:"text"`
+	Metadata *Metadata `json:"metadata"`
+}
+
+// Returned by UpdateNote when the note was changed on disk after the editor
+// loaded it. Holds both versions, so they can be merged and saved again
+// with the version of Theirs
+type ConflictError struct {
+	NoteId string       `json:"noteId"`
+	Mine   NoteSnapshot `json:"mine"`
+	Theirs NoteSnapshot `json:"theirs"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("Note %s was changed since it was loaded", e.NoteId)
+}
@@ -0,0 +1,175 @@
+package notes
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestUpdateNoteConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		// Changes the note on disk after the editor loaded it
+		change   func(t *testing.T, noteId string)
+		stale    bool
+		conflict bool
+		// Text left on disk
+		want string
+	}{
+		{
+			name: "unchanged note is saved",
+			want: "mine",
+		},
+		{
+			name: "note changed by another program",
+			change: func(t *testing.T, noteId string) {
+				if err := os.WriteFile(getNoteFile(noteId), []byte("---\ntitle: Sync\n---\ntheirs"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			conflict: true,
+			want:     "theirs",
+		},
+		{
+			name: "note changed by another save",
+			change: func(t *testing.T, noteId string) {
+				if _, err := UpdateNote(noteId, "theirs", &Metadata{Title: "Sync"}, ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			conflict: true,
+			want:     "theirs",
+		},
+		{
+			name: "note removed from disk",
+			change: func(t *testing.T, noteId string) {
+				if err := os.Remove(getNoteFile(noteId)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			conflict: true,
+		},
+		{
+			name:  "save without a version overwrites",
+			stale: true,
+			change: func(t *testing.T, noteId string) {
+				if _, err := UpdateNote(noteId, "theirs", &Metadata{Title: "Sync"}, ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "mine",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := setupTestNotes(t, LayoutFolders)
+
+			noteId, err := n.CreateNoteFrom("Sync", SourceTyped)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loaded := n.FindNote(noteId).Version
+			if test.change != nil {
+				test.change(t, noteId)
+			}
+
+			baseVersion := loaded
+			if test.stale {
+				baseVersion = ""
+			}
+
+			version, err := UpdateNote(noteId, "mine", &Metadata{Title: "Sync"}, baseVersion)
+
+			var conflict *ConflictError
+			if errors.As(err, &conflict) != test.conflict {
+				t.Fatalf("Expected conflict %v, got %v", test.conflict, err)
+			}
+
+			if test.conflict {
+				if conflict.Mine.Text != "mine" {
+					t.Errorf("Unsaved text is lost, got %q", conflict.Mine.Text)
+				}
+				if conflict.Theirs.Text != test.want {
+					t.Errorf("Expected their text %q, got %q", test.want, conflict.Theirs.Text)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			_, text, err := readNoteFile(noteId)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				t.Fatal(err)
+			}
+
+			if current, _ := noteVersion(noteId); !test.conflict && current != version {
+				t.Errorf("Returned version %s, note has %s", version, current)
+			}
+
+			if strings.TrimSpace(text) != test.want {
+				t.Errorf("Expected %q on disk, got %q", test.want, text)
+			}
+		})
+	}
+}
+
+func TestConflictResolvedWithTheirVersion(t *testing.T) {
+	n := setupTestNotes(t, LayoutFolders)
+
+	noteId, err := n.CreateNoteFrom("Sync", SourceTyped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := n.FindNote(noteId).Version
+	if _, err := UpdateNote(noteId, "theirs", &Metadata{Title: "Sync"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var conflict *ConflictError
+	if _, err := UpdateNote(noteId, "mine", &Metadata{Title: "Sync"}, loaded); !errors.As(err, &conflict) {
+		t.Fatalf("Expected a conflict, got %v", err)
+	}
+
+	if _, err := UpdateNote(noteId, "merged", &Metadata{Title: "Sync"}, conflict.Theirs.Version); err != nil {
+		t.Fatalf("Merged text wasn't saved: %v", err)
+	}
+
+	text, err := n.FindNote(noteId).ReadData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(text) != "merged" {
+		t.Errorf("Expected merged text, got %q", text)
+	}
+}
+
+func TestWriteFileAtomicLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "note.md")
+
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(filePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bytes, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != "second" {
+		t.Errorf("Expected the last write, got %q", bytes)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the note file, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,193 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/search"
+	"github.com/spf13/viper"
+)
+
+const searchIndexFile = "search.json"
+
+var (
+	indexOnce   sync.Once
+	searchIndex *search.Index
+)
+
+func getSearchIndex() *search.Index {
+	indexOnce.Do(func() {
+		indexPath := os.ExpandEnv(viper.GetString("IndexPath"))
+		searchIndex = search.NewIndex(path.Join(indexPath, searchIndexFile))
+	})
+
+	return searchIndex
+}
+
+// Writes search index changes that are waiting to be saved
+func FlushSearchIndex() error {
+	return getSearchIndex().Flush()
+}
+
+func transcriptDocuments(audio AudioFile, lang string) []search.Document {
+	result := audio.Transcript
+	if result == nil || !result.SegmentsMatchText() {
+		if result != nil && result.Language != "" {
+			lang = result.Language
+		}
+
+		return []search.Document{{
+			Field:       search.FieldTranscript,
+			RecordingId: audio.Id,
+			Language:    lang,
+			Text:        audio.Text,
+		}}
+	}
+
+	docs := []search.Document{}
+	for _, segment := range result.Segments {
+		segmentLang := segment.Language
+		if segmentLang == "" {
+			segmentLang = result.Language
+		}
+
+		docs = append(docs, search.Document{
+			Field:       search.FieldTranscript,
+			RecordingId: audio.Id,
+			Start:       segment.Start,
+			End:         segment.End,
+			Language:    segmentLang,
+			Text:        segment.Text,
+		})
+	}
+
+	return docs
+}
+
+// Everything searchable in the note: title, tags, text and recordings
+func noteDocuments(noteId string) ([]search.Document, error) {
+	header, body, err := readNoteFile(noteId)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := decodeMetadata(header)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid metadata of note %s: %w", noteId, err)
+	}
+
+	// Typed notes have no detected language, they are likely in the preferred one
+	lang := meta.Language
+	if lang == "" {
+		lang = viper.GetString("PreferedLanguage")
+	}
+	if lang == "auto" {
+		lang = ""
+	}
+
+	docs := []search.Document{
+		{Field: search.FieldTitle, Language: lang, Text: meta.Title},
+		{Field: search.FieldTags, Language: lang, Text: strings.Join(meta.Tags, " ")},
+		{Field: search.FieldBody, Language: lang, Text: body},
+	}
+
+	note := NoteInfo{Id: noteId}
+	audios, err := note.ListAudio()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, audio := range audios {
+		docs = append(docs, transcriptDocuments(audio, lang)...)
+	}
+
+	return docs, nil
+}
+
+func reindexNote(noteId string) error {
+	docs, err := noteDocuments(noteId)
+	if err != nil {
+		return fmt.Errorf("Couldn't read note %s for indexing: %w", noteId, err)
+	}
+
+	return getSearchIndex().UpdateNote(noteId, docs)
+}
+
+// Latest change to any file of the note
+func noteChangedAt(noteId string) time.Time {
+	latest := time.Time{}
+	layout := getLayout()
+
+	if stat, err := os.Stat(layout.NoteFile(noteId)); err == nil {
+		latest = stat.ModTime()
+	}
+
+	files, err := os.ReadDir(layout.RecordingsDir(noteId))
+	if err != nil {
+		return latest
+	}
+
+	for _, file := range files {
+		if info, err := file.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
+
+// Reindexes notes changed since they were indexed, e.g. while the app wasn't
+// running, and drops notes that no longer exist
+func (n *Notes) SyncIndex() error {
+	notes, err := n.ListNotes()
+	if err != nil {
+		return err
+	}
+
+	index := getSearchIndex()
+	existing := map[string]bool{}
+	changed := map[string][]search.Document{}
+	removed := []string{}
+	errs := []error{}
+
+	for _, note := range notes {
+		existing[note.Id] = true
+
+		if !noteChangedAt(note.Id).After(index.IndexedAt(note.Id)) {
+			continue
+		}
+
+		docs, err := noteDocuments(note.Id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't read note %s for indexing: %w", note.Id, err))
+			continue
+		}
+
+		changed[note.Id] = docs
+	}
+
+	for _, noteId := range index.NoteIds() {
+		if !existing[noteId] {
+			removed = append(removed, noteId)
+		}
+	}
+
+	if len(changed) > 0 || len(removed) > 0 {
+		if err := index.UpdateNotes(changed, removed); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Notes matching the query, best first. Transcript matches point to the
+// recording and the time the words were said
+func (n *Notes) Search(query string, limit int) []search.Result {
+	return getSearchIndex().Search(query, limit)
+}
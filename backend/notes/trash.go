@@ -0,0 +1,283 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Kept inside the notes directory, so moving a note there is a rename
+	// on the same disk. Dot directories aren't listed as notes
+	trashDir = ".trash"
+	// Written into a trashed note, holds when it was trashed
+	trashInfoFile = ".trashed"
+)
+
+type TrashedNote struct {
+	Id      string    `json:"id"`
+	Title   string    `json:"title"`
+	Trashed time.Time `json:"trashed"`
+	// When the note gets deleted for good, zero when trash is kept forever
+	PurgeAt time.Time `json:"purgeAt"`
+	Size    int64     `json:"size"`
+}
+
+func getTrashPath() string {
+	return path.Join(getNotesRoot(), trashDir)
+}
+
+// Trashed notes are kept the same way as the others, just in the trash
+func getTrashLayout() Layout {
+	return layoutAt(getTrashPath())
+}
+
+// Moves files of the note between two roots of the same layout
+func moveNote(from, to Layout, noteId string) error {
+	for _, notePath := range from.NotePaths(noteId) {
+		source := path.Join(from.Root(), notePath)
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+
+		target := path.Join(to.Root(), notePath)
+		if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		if err := os.Rename(source, target); err != nil {
+			return err
+		}
+
+		// Shared folders of the layout, like attachments of the vault
+		removeEmptyDirs(from.Root(), path.Dir(source))
+	}
+
+	return nil
+}
+
+func removeNote(layout Layout, noteId string) (bool, error) {
+	found := false
+	for _, notePath := range layout.NotePaths(noteId) {
+		fullPath := path.Join(layout.Root(), notePath)
+		if _, err := os.Stat(fullPath); err != nil {
+			continue
+		}
+
+		found = true
+		if err := os.RemoveAll(fullPath); err != nil {
+			return found, err
+		}
+	}
+
+	return found, nil
+}
+
+func validateNoteId(noteId string) error {
+	if noteId == "" || strings.HasPrefix(noteId, ".") || strings.ContainsAny(noteId, `/\`) {
+		return fmt.Errorf("Invalid note id %q", noteId)
+	}
+
+	return nil
+}
+
+// Moves the note into the trash, it can be restored until the trash is purged
+func (n *Notes) TrashNote(noteId string) error {
+	if err := validateNoteId(noteId); err != nil {
+		return err
+	}
+
+	if !noteExists(noteId) {
+		return fmt.Errorf("Note %s doesn't exist", noteId)
+	}
+
+	trash := getTrashLayout()
+
+	// Leftover of an earlier trashing of the same note, the current one wins
+	if _, err := removeNote(trash, noteId); err != nil {
+		return fmt.Errorf("Couldn't replace trashed note %s: %w", noteId, err)
+	}
+
+	if err := moveNote(getLayout(), trash, noteId); err != nil {
+		return fmt.Errorf("Couldn't move note %s to trash: %w", noteId, err)
+	}
+
+	infoPath := path.Join(trash.RecordingsDir(noteId), trashInfoFile)
+	trashed := time.Now().UTC().Format(time.RFC3339)
+	if err := os.MkdirAll(path.Dir(infoPath), 0755); err != nil {
+		fmt.Println("Couldn't write when note was trashed:", err)
+	} else if err := os.WriteFile(infoPath, []byte(trashed), 0600); err != nil {
+		fmt.Println("Couldn't write when note was trashed:", err)
+	}
+
+	if err := getSearchIndex().RemoveNote(noteId); err != nil {
+		fmt.Println("Couldn't update search index:", err)
+	}
+
+	return nil
+}
+
+func (n *Notes) RestoreNote(noteId string) error {
+	if err := validateNoteId(noteId); err != nil {
+		return err
+	}
+
+	trash := getTrashLayout()
+	if _, err := noteStat(trash, noteId); err != nil {
+		return fmt.Errorf("Note %s isn't in the trash: %w", noteId, err)
+	}
+
+	if noteExists(noteId) {
+		return fmt.Errorf("Note %s already exists", noteId)
+	}
+
+	if err := os.Remove(path.Join(trash.RecordingsDir(noteId), trashInfoFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// Folder made just for the info file, a note with files keeps it
+	removeEmptyDirs(trash.Root(), trash.RecordingsDir(noteId))
+
+	if err := moveNote(trash, getLayout(), noteId); err != nil {
+		return fmt.Errorf("Couldn't restore note %s: %w", noteId, err)
+	}
+
+	if err := reindexNote(noteId); err != nil {
+		fmt.Println("Couldn't update search index:", err)
+	}
+
+	return nil
+}
+
+// Deletes the note with its recordings for good, trashed or not
+func (n *Notes) DeleteNote(noteId string) error {
+	if err := validateNoteId(noteId); err != nil {
+		return err
+	}
+
+	found := false
+	for _, layout := range []Layout{getLayout(), getTrashLayout()} {
+		removed, err := removeNote(layout, noteId)
+		if err != nil {
+			return fmt.Errorf("Couldn't delete note %s: %w", noteId, err)
+		}
+
+		found = found || removed
+	}
+
+	if !found {
+		return fmt.Errorf("Note %s doesn't exist", noteId)
+	}
+
+	if err := getSearchIndex().RemoveNote(noteId); err != nil {
+		fmt.Println("Couldn't update search index:", err)
+	}
+
+	return nil
+}
+
+// Notes in the trash were trashed by the app, their info file tells when.
+// Anything else put there counts as trashed when it was last changed
+func readTrashedNote(noteId string, retentionDays int) (TrashedNote, error) {
+	trash := getTrashLayout()
+	stat, err := noteStat(trash, noteId)
+	if err != nil {
+		return TrashedNote{}, err
+	}
+
+	note := TrashedNote{
+		Id:      noteId,
+		Trashed: stat.ModTime(),
+	}
+
+	for _, notePath := range trash.NotePaths(noteId) {
+		note.Size += dirSize(path.Join(trash.Root(), notePath))
+	}
+
+	if info, err := os.ReadFile(path.Join(trash.RecordingsDir(noteId), trashInfoFile)); err == nil {
+		if trashed, err := time.Parse(time.RFC3339, strings.TrimSpace(string(info))); err == nil {
+			note.Trashed = trashed
+		}
+	}
+
+	if header, _, err := readNoteFileAt(trash.NoteFile(noteId)); err == nil {
+		if meta, err := decodeMetadata(header); err == nil {
+			note.Title = meta.Title
+		}
+	}
+
+	if retentionDays > 0 {
+		note.PurgeAt = note.Trashed.AddDate(0, 0, retentionDays)
+	}
+
+	return note, nil
+}
+
+// Trashed notes, most recently trashed first
+func (n *Notes) ListTrash() ([]TrashedNote, error) {
+	trashed := []TrashedNote{}
+
+	noteIds, err := getTrashLayout().NoteIds()
+	if err != nil {
+		return trashed, fmt.Errorf("Couldn't read trash: %w", err)
+	}
+
+	retentionDays := n.cfg.GetConfig().TrashRetentionDays
+
+	for _, noteId := range noteIds {
+		note, err := readTrashedNote(noteId, retentionDays)
+		if err != nil {
+			fmt.Println("Error while reading trashed note:", err)
+			continue
+		}
+
+		trashed = append(trashed, note)
+	}
+
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].Trashed.After(trashed[j].Trashed)
+	})
+
+	return trashed, nil
+}
+
+func (n *Notes) EmptyTrash() error {
+	if err := os.RemoveAll(getTrashPath()); err != nil {
+		return fmt.Errorf("Couldn't empty trash: %w", err)
+	}
+
+	return nil
+}
+
+// Deletes notes that stayed in the trash longer than TrashRetentionDays,
+// returns ids of the deleted notes
+func (n *Notes) PurgeTrash() ([]string, error) {
+	purged := []string{}
+
+	trashed, err := n.ListTrash()
+	if err != nil {
+		return purged, err
+	}
+
+	now := time.Now()
+	errs := []error{}
+
+	for _, note := range trashed {
+		if note.PurgeAt.IsZero() || note.PurgeAt.After(now) {
+			continue
+		}
+
+		if _, err := removeNote(getTrashLayout(), note.Id); err != nil {
+			errs = append(errs, fmt.Errorf("Couldn't purge note %s: %w", note.Id, err))
+			continue
+		}
+
+		purged = append(purged, note.Id)
+	}
+
+	return purged, errors.Join(errs...)
+}
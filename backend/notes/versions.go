@@ -0,0 +1,229 @@
+package notes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// Older transcripts of every recording live in versions/<recordingId>/<unix ms>.json
+const versionsDir = "versions"
+
+// Id of the transcript currently in use
+const CurrentRevision = "current"
+
+type Revision struct {
+	// CurrentRevision or unix milliseconds of when the transcript was written
+	Id     string    `json:"id"`
+	Date   time.Time `json:"date"`
+	Source string    `json:"source"`
+	Model  string    `json:"model,omitempty"`
+	Engine string    `json:"engine,omitempty"`
+	// Profile of the engine settings, runs with different ones can be told apart
+	Profile string `json:"profile,omitempty"`
+}
+
+func newRevision(id string, date time.Time, result *transcript.Transcript) Revision {
+	source := result.Source
+	if source == "" {
+		source = transcript.SourceTranscription
+	}
+
+	return Revision{
+		Id:      id,
+		Date:    date,
+		Source:  source,
+		Model:   result.Model,
+		Engine:  result.Engine,
+		Profile: result.Profile,
+	}
+}
+
+func (n *NoteInfo) versionsPath(recordingId string) string {
+	return path.Join(n.getPath(), versionsDir, recordingId)
+}
+
+// Copies the current transcript into the versions directory. Recordings made
+// before transcripts were stored get a version with just the text
+func (n *NoteInfo) archiveTranscript(recordingId string) error {
+	result, date, err := n.currentTranscript(recordingId)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("Invalid transcript format: %w", err)
+	}
+
+	versionsPath := n.versionsPath(recordingId)
+	if err := os.MkdirAll(versionsPath, 0755); err != nil {
+		return err
+	}
+
+	// Named after the time the transcript was written, not archived
+	return writeFileAtomic(path.Join(versionsPath, fmt.Sprintf("%d.json", date.UnixMilli())), bytes, 0600)
+}
+
+// Transcript in use, recordings made before transcripts were stored only have the text
+func (n *NoteInfo) currentTranscript(recordingId string) (*transcript.Transcript, time.Time, error) {
+	recordingPath, err := n.getRecordingPath(recordingId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	stat, err := os.Stat(recordingPath + ".txt")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("Couldn't read transcript of %s: %w", recordingId, err)
+	}
+
+	result, err := readTranscript(recordingPath + ".wav")
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if result == nil {
+		text, err := os.ReadFile(recordingPath + ".txt")
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		result = &transcript.Transcript{Text: string(text)}
+	}
+
+	return result, stat.ModTime(), nil
+}
+
+// Current transcript first, then older ones from newest to oldest
+func (n *NoteInfo) ListRevisions(recordingId string) ([]Revision, error) {
+	revisions := []Revision{}
+
+	current, date, err := n.currentTranscript(recordingId)
+	if err != nil {
+		return revisions, err
+	}
+	revisions = append(revisions, newRevision(CurrentRevision, date, current))
+
+	files, err := os.ReadDir(n.versionsPath(recordingId))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return revisions, nil
+		}
+
+		return revisions, err
+	}
+
+	archived := []Revision{}
+	for _, file := range files {
+		id := strings.TrimSuffix(file.Name(), ".json")
+		ms, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || file.IsDir() {
+			continue
+		}
+
+		result, err := n.ReadRevision(recordingId, id)
+		if err != nil {
+			fmt.Println("Skipping broken revision", file.Name(), err)
+			continue
+		}
+
+		archived = append(archived, newRevision(id, time.UnixMilli(ms), result))
+	}
+
+	sort.Slice(archived, func(i, j int) bool {
+		return archived[i].Date.After(archived[j].Date)
+	})
+
+	return append(revisions, archived...), nil
+}
+
+func (n *NoteInfo) ReadRevision(recordingId, revisionId string) (*transcript.Transcript, error) {
+	if revisionId == CurrentRevision {
+		result, _, err := n.currentTranscript(recordingId)
+		return result, err
+	}
+
+	if _, err := strconv.ParseInt(revisionId, 10, 64); err != nil {
+		return nil, fmt.Errorf("Invalid revision id %q", revisionId)
+	}
+
+	if _, err := n.getRecordingPath(recordingId); err != nil {
+		return nil, err
+	}
+
+	bytes, err := os.ReadFile(path.Join(n.versionsPath(recordingId), revisionId+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read revision %s: %w", revisionId, err)
+	}
+
+	var result transcript.Transcript
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		return nil, fmt.Errorf("Invalid revision file: %w", err)
+	}
+
+	return &result, nil
+}
+
+// Word by word difference of the shown text between two revisions
+func (n *NoteInfo) DiffRevisions(recordingId, fromId, toId string) ([]transcript.DiffOp, error) {
+	from, err := n.ReadRevision(recordingId, fromId)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := n.ReadRevision(recordingId, toId)
+	if err != nil {
+		return nil, err
+	}
+
+	return transcript.DiffWords(from.DisplayText(), to.DisplayText()), nil
+}
+
+// Makes an older revision current again, the current one is archived first
+func (n *NoteInfo) RestoreRevision(recordingId, revisionId string) error {
+	if revisionId == CurrentRevision {
+		return nil
+	}
+
+	result, err := n.ReadRevision(recordingId, revisionId)
+	if err != nil {
+		return err
+	}
+
+	result.Source = transcript.SourceRestore
+
+	return n.ReplaceTranscript(recordingId, result)
+}
+
+// Saves text fixed by hand as a new revision. Timings are kept, words that
+// no longer match the text are skipped by the lookups
+func (n *NoteInfo) EditTranscript(recordingId, text string) error {
+	current, _, err := n.currentTranscript(recordingId)
+	if err != nil {
+		return err
+	}
+
+	if current.DisplayText() == text {
+		return nil
+	}
+
+	edited := *current
+	edited.Text = text
+	edited.Clean = ""
+	edited.Source = transcript.SourceManual
+	edited.Original = nil
+
+	return n.ReplaceTranscript(recordingId, &edited)
+}
@@ -0,0 +1,277 @@
+package notes
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Events emitted when notes change on disk, by the app or anything else
+const (
+	EventNoteCreated    = "notes:created"
+	EventNoteUpdated    = "notes:updated"
+	EventNoteDeleted    = "notes:deleted"
+	EventRecordingAdded = "notes:recording:added"
+)
+
+// Editors and sync tools write a file in several steps, changes are
+// reported once they settle
+const watchDebounce = 300 * time.Millisecond
+
+type NoteEvent struct {
+	NoteId      string `json:"noteId"`
+	RecordingId string `json:"recordingId,omitempty"`
+	// Version of the note after the change, the editor can tell its own saves by it
+	Version string `json:"version,omitempty"`
+}
+
+type noteWatcher struct {
+	notes   *Notes
+	watcher *fsnotify.Watcher
+	root    string
+
+	mu    sync.Mutex
+	known map[string]bool
+	// Changed notes with recordings that appeared in them
+	pending map[string][]string
+	timer   *time.Timer
+}
+
+var (
+	watcherMu     sync.Mutex
+	activeWatcher *noteWatcher
+)
+
+// Watches the notes directory until the app context is done. A watcher
+// started before is stopped
+func (n *Notes) Watch() error {
+	root := n.cfg.GetConfig().NotesPath
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("Couldn't create notes directory: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Couldn't start watching notes: %w", err)
+	}
+
+	w := &noteWatcher{
+		notes:   n,
+		watcher: watcher,
+		root:    root,
+		known:   map[string]bool{},
+		pending: map[string][]string{},
+	}
+	w.timer = time.AfterFunc(watchDebounce, w.flush)
+	w.timer.Stop()
+
+	if err := watcher.Add(root); err != nil {
+		watcher.Close()
+		return fmt.Errorf("Couldn't watch %s: %w", root, err)
+	}
+
+	notes, err := n.ListNotes()
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	for _, note := range notes {
+		w.known[note.Id] = true
+	}
+
+	w.addDirs(root)
+
+	stopWatching()
+	watcherMu.Lock()
+	activeWatcher = w
+	watcherMu.Unlock()
+
+	go w.run()
+
+	return nil
+}
+
+// Changes made until Watch runs again aren't reported. False when notes
+// weren't watched
+func stopWatching() bool {
+	watcherMu.Lock()
+	w := activeWatcher
+	activeWatcher = nil
+	watcherMu.Unlock()
+
+	if w == nil {
+		return false
+	}
+
+	w.timer.Stop()
+	// Closes the event channels, which ends run
+	if err := w.watcher.Close(); err != nil {
+		fmt.Println("Couldn't stop watching notes:", err)
+	}
+
+	return true
+}
+
+func (w *noteWatcher) run() {
+	defer w.watcher.Close()
+
+	for {
+		select {
+		case <-w.notes.ctx.Done():
+			w.timer.Stop()
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Println("Error while watching notes:", err)
+		}
+	}
+}
+
+// fsnotify isn't recursive, every folder is watched on its own
+func (w *noteWatcher) addDirs(dir string) {
+	filepath.WalkDir(dir, func(dirPath string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+
+		if strings.HasPrefix(entry.Name(), ".") && dirPath != w.root {
+			return filepath.SkipDir
+		}
+
+		if err := w.watcher.Add(dirPath); err != nil {
+			fmt.Println("Couldn't watch notes folder:", err)
+		}
+
+		return nil
+	})
+}
+
+func (w *noteWatcher) handle(event fsnotify.Event) {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+		return
+	}
+
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+
+	// The trash, temporary files of atomic saves and folders of other tools
+	if slices.ContainsFunc(strings.Split(rel, "/"), func(part string) bool {
+		return strings.HasPrefix(part, ".")
+	}) {
+		return
+	}
+
+	// Folders moved in come with everything inside
+	if event.Has(fsnotify.Create) {
+		if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() {
+			w.addDirs(event.Name)
+		}
+	}
+
+	layout := getLayout()
+	noteId := layout.NoteOf(rel)
+	if noteId == "" {
+		return
+	}
+
+	recordingId := ""
+	if event.Has(fsnotify.Create) && path.Dir(rel) == layoutAt("").RecordingsDir(noteId) && strings.HasSuffix(rel, ".wav") {
+		recordingId = strings.TrimSuffix(path.Base(rel), ".wav")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	recordings := w.pending[noteId]
+	if recordingId != "" && !slices.Contains(recordings, recordingId) {
+		recordings = append(recordings, recordingId)
+	}
+	w.pending[noteId] = recordings
+
+	w.timer.Reset(watchDebounce)
+}
+
+func (w *noteWatcher) emit(name string, event NoteEvent) {
+	runtime.EventsEmit(w.notes.ctx, name, event)
+}
+
+func (w *noteWatcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = map[string][]string{}
+	w.mu.Unlock()
+
+	index := getSearchIndex()
+
+	for noteId, recordings := range pending {
+		scheduleCommit(noteId)
+
+		exists := noteExists(noteId)
+
+		w.mu.Lock()
+		known := w.known[noteId]
+		w.known[noteId] = exists
+		w.mu.Unlock()
+
+		if !exists {
+			if !known {
+				continue
+			}
+
+			if err := index.RemoveNote(noteId); err != nil {
+				fmt.Println("Couldn't update search index:", err)
+			}
+
+			w.emit(EventNoteDeleted, NoteEvent{NoteId: noteId})
+			continue
+		}
+
+		// Saves made by the app are indexed already
+		if noteChangedAt(noteId).After(index.IndexedAt(noteId)) {
+			if err := reindexNote(noteId); err != nil {
+				fmt.Println("Couldn't update search index:", err)
+			}
+		}
+
+		version, err := noteVersion(noteId)
+		if err != nil {
+			fmt.Println("Error while reading note version:", err)
+		}
+
+		event := NoteEvent{NoteId: noteId, Version: version}
+		if known {
+			w.emit(EventNoteUpdated, event)
+		} else {
+			w.emit(EventNoteCreated, event)
+		}
+
+		slices.Sort(recordings)
+		for _, recordingId := range recordings {
+			if _, err := os.Stat(path.Join(getLayout().RecordingsDir(noteId), recordingId+".wav")); err != nil {
+				continue
+			}
+
+			w.emit(EventRecordingAdded, NoteEvent{NoteId: noteId, RecordingId: recordingId, Version: version})
+		}
+	}
+}
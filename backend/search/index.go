@@ -0,0 +1,337 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// Parts of a note that are indexed separately
+const (
+	FieldTitle      = "title"
+	FieldTags       = "tags"
+	FieldBody       = "body"
+	FieldTranscript = "transcript"
+)
+
+// Bumped when the stored format or tokenisation changes, old indexes get rebuilt
+const indexVersion = 1
+
+// Changes are written once notes stop changing for this long, saving every
+// keystroke would rewrite the whole index each time. Notes changed after the
+// last write are reindexed by their modification time on the next start
+const saveDelay = 2 * time.Second
+
+// Matches in titles and tags say more about a note than a word in the body
+var fieldWeights = map[string]float64{
+	FieldTitle:      3,
+	FieldTags:       2,
+	FieldBody:       1,
+	FieldTranscript: 1,
+}
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Piece of a note that can be found. Transcripts are indexed per segment,
+// so a match knows where in the recording it was said
+type Document struct {
+	NoteId      string `json:"noteId"`
+	Field       string `json:"field"`
+	RecordingId string `json:"recordingId,omitempty"`
+	// Position in the recording, ms
+	Start    int64  `json:"start,omitempty"`
+	End      int64  `json:"end,omitempty"`
+	Language string `json:"language,omitempty"`
+	Text     string `json:"text"`
+}
+
+type storedDocument struct {
+	Document
+	// Number of terms, for length normalization
+	Length int `json:"length"`
+}
+
+type indexedNote struct {
+	Docs    []int     `json:"docs"`
+	Indexed time.Time `json:"indexed"`
+}
+
+type indexData struct {
+	Version  int                     `json:"version"`
+	NextId   int                     `json:"nextId"`
+	Docs     map[int]*storedDocument `json:"docs"`
+	Notes    map[string]*indexedNote `json:"notes"`
+	Postings map[string]map[int]int  `json:"postings"`
+	// Number of documents per language, queries are stemmed for each of them
+	Languages   map[string]int `json:"languages"`
+	TotalLength int            `json:"totalLength"`
+}
+
+// Inverted index of notes kept in a single file
+type Index struct {
+	mu   sync.RWMutex
+	path string
+	data indexData
+
+	// Changes not written to the file yet
+	dirty     bool
+	saveTimer *time.Timer
+}
+
+func emptyIndexData() indexData {
+	return indexData{
+		Version:   indexVersion,
+		Docs:      map[int]*storedDocument{},
+		Notes:     map[string]*indexedNote{},
+		Postings:  map[string]map[int]int{},
+		Languages: map[string]int{},
+	}
+}
+
+// Loads the index stored at path. Missing, broken or outdated files give an
+// empty index, the caller is expected to reindex notes then
+func NewIndex(path string) *Index {
+	index := &Index{path: path, data: emptyIndexData()}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("Couldn't read search index:", err)
+		}
+		return index
+	}
+
+	var data indexData
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		fmt.Println("Search index is broken, it will be rebuilt:", err)
+		return index
+	}
+
+	if data.Version == indexVersion && data.Docs != nil && data.Notes != nil && data.Postings != nil && data.Languages != nil {
+		index.data = data
+	}
+
+	return index
+}
+
+func (i *Index) save() error {
+	bytes, err := json.Marshal(i.data)
+	if err != nil {
+		return fmt.Errorf("Invalid search index format: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(i.path), 0755); err != nil {
+		return fmt.Errorf("Couldn't create search index directory: %w", err)
+	}
+
+	// Written next to the index first, so a crash doesn't leave half of it
+	tmpPath := i.path + ".tmp"
+	if err := os.WriteFile(tmpPath, bytes, 0600); err != nil {
+		return fmt.Errorf("Couldn't write search index: %w", err)
+	}
+
+	return os.Rename(tmpPath, i.path)
+}
+
+func (i *Index) scheduleSave() {
+	i.dirty = true
+
+	if i.saveTimer == nil {
+		i.saveTimer = time.AfterFunc(saveDelay, func() {
+			if err := i.Flush(); err != nil {
+				fmt.Println("Couldn't save search index:", err)
+			}
+		})
+		return
+	}
+
+	i.saveTimer.Reset(saveDelay)
+}
+
+// Writes pending changes right away, e.g. before the app exits
+func (i *Index) Flush() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !i.dirty {
+		return nil
+	}
+
+	if err := i.save(); err != nil {
+		return err
+	}
+
+	i.dirty = false
+	return nil
+}
+
+func (i *Index) removeNote(noteId string) {
+	note, ok := i.data.Notes[noteId]
+	if !ok {
+		return
+	}
+
+	for _, id := range note.Docs {
+		doc := i.data.Docs[id]
+		if doc == nil {
+			continue
+		}
+
+		for _, token := range Analyze(doc.Text, doc.Language) {
+			postings := i.data.Postings[token.Term]
+			delete(postings, id)
+			if len(postings) == 0 {
+				delete(i.data.Postings, token.Term)
+			}
+		}
+
+		i.data.TotalLength -= doc.Length
+		if i.data.Languages[doc.Language]--; i.data.Languages[doc.Language] <= 0 {
+			delete(i.data.Languages, doc.Language)
+		}
+
+		delete(i.data.Docs, id)
+	}
+
+	delete(i.data.Notes, noteId)
+}
+
+func (i *Index) updateNote(noteId string, docs []Document) {
+	i.removeNote(noteId)
+	note := &indexedNote{Indexed: time.Now()}
+
+	for _, doc := range docs {
+		doc.NoteId = noteId
+		doc.Language = transcript.LanguageCode(doc.Language)
+		tokens := Analyze(doc.Text, doc.Language)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		id := i.data.NextId
+		i.data.NextId++
+
+		for _, token := range tokens {
+			if i.data.Postings[token.Term] == nil {
+				i.data.Postings[token.Term] = map[int]int{}
+			}
+			i.data.Postings[token.Term][id]++
+		}
+
+		i.data.Docs[id] = &storedDocument{Document: doc, Length: len(tokens)}
+		i.data.Languages[doc.Language]++
+		i.data.TotalLength += len(tokens)
+		note.Docs = append(note.Docs, id)
+	}
+
+	i.data.Notes[noteId] = note
+}
+
+// Replaces everything indexed for the note with docs
+func (i *Index) UpdateNote(noteId string, docs []Document) error {
+	return i.UpdateNotes(map[string][]Document{noteId: docs}, nil)
+}
+
+// Reindexes changed notes and drops removed ones, the index is saved later
+func (i *Index) UpdateNotes(changed map[string][]Document, removed []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for noteId, docs := range changed {
+		i.updateNote(noteId, docs)
+	}
+
+	for _, noteId := range removed {
+		i.removeNote(noteId)
+	}
+
+	i.scheduleSave()
+
+	return nil
+}
+
+func (i *Index) RemoveNote(noteId string) error {
+	return i.UpdateNotes(nil, []string{noteId})
+}
+
+// When the note was last indexed, zero if it never was
+func (i *Index) IndexedAt(noteId string) time.Time {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if note, ok := i.data.Notes[noteId]; ok {
+		return note.Indexed
+	}
+
+	return time.Time{}
+}
+
+func (i *Index) NoteIds() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	ids := []string{}
+	for id := range i.data.Notes {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (i *Index) languages() []string {
+	langs := []string{}
+	for lang := range i.data.Languages {
+		if lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+
+	// Map order is random, the variants shouldn't be
+	sort.Strings(langs)
+
+	return langs
+}
+
+func (i *Index) bm25(term string, docId int) float64 {
+	postings := i.data.Postings[term]
+	freq := float64(postings[docId])
+	if freq == 0 {
+		return 0
+	}
+
+	total := float64(len(i.data.Docs))
+	idf := math.Log(1 + (total-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+
+	avgLength := float64(i.data.TotalLength) / total
+	length := float64(i.data.Docs[docId].Length)
+
+	return idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*length/avgLength))
+}
+
+// Query words with the terms each of them could be indexed by
+func (i *Index) queryTerms(query string) [][]string {
+	langs := i.languages()
+	terms := [][]string{}
+
+	for _, token := range tokenize(query) {
+		variants := stemVariants(token.Term, langs)
+		if !slices.ContainsFunc(terms, func(existing []string) bool { return slices.Equal(existing, variants) }) {
+			terms = append(terms, variants)
+		}
+	}
+
+	return terms
+}
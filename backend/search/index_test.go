@@ -0,0 +1,139 @@
+package search
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func noteIds(results []Result) []string {
+	ids := []string{}
+	for _, result := range results {
+		ids = append(ids, result.NoteId)
+	}
+	slices.Sort(ids)
+
+	return ids
+}
+
+func testIndex(t *testing.T) *Index {
+	index := NewIndex(filepath.Join(t.TempDir(), "index.json"))
+
+	notes := map[string][]Document{
+		"standup": {
+			{Field: FieldTitle, Language: "en", Text: "Daily standup"},
+			{Field: FieldTranscript, RecordingId: "r1", Start: 1000, End: 4000, Language: "en", Text: "We discussed the upcoming meetings and the budget"},
+		},
+		"reunion": {
+			{Field: FieldTitle, Language: "fr", Text: "Réunion"},
+			{Field: FieldTranscript, RecordingId: "r2", Language: "french", Text: "Les réunions de la semaine prochaine"},
+		},
+		"groceries": {
+			{Field: FieldTitle, Language: "en", Text: "Groceries"},
+			{Field: FieldBody, Language: "en", Text: "Buy apples and bread"},
+		},
+	}
+	if err := index.UpdateNotes(notes, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return index
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "exact word", query: "budget", want: []string{"standup"}},
+		{name: "plural matches singular", query: "meeting", want: []string{"standup"}},
+		{name: "singular matches plural", query: "apple", want: []string{"groceries"}},
+		{name: "stemmed in the language of the transcript", query: "réunion", want: []string{"reunion"}},
+		{name: "accents are folded", query: "reunions", want: []string{"reunion"}},
+		{name: "every word must be found", query: "budget apples", want: []string{}},
+		{name: "words from different fields of a note", query: "standup budget", want: []string{"standup"}},
+		{name: "unknown word", query: "holiday", want: []string{}},
+		{name: "empty query", query: "  ", want: []string{}},
+	}
+
+	index := testIndex(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := noteIds(index.Search(test.query, 0))
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSearchMatchPosition(t *testing.T) {
+	results := testIndex(t).Search("budget", 0)
+	if len(results) != 1 || len(results[0].Matches) != 1 {
+		t.Fatalf("Expected a single match, got %+v", results)
+	}
+
+	match := results[0].Matches[0]
+	if match.RecordingId != "r1" || match.Start != 1000 || match.End != 4000 {
+		t.Errorf("Match points at %s %d-%d", match.RecordingId, match.Start, match.End)
+	}
+
+	if len(match.Highlights) != 1 {
+		t.Fatalf("Expected a single highlight, got %+v", match.Highlights)
+	}
+
+	runes := []rune(match.Snippet)
+	if got := string(runes[match.Highlights[0].Start:match.Highlights[0].End]); got != "budget" {
+		t.Errorf("Highlight points at %q", got)
+	}
+}
+
+func TestUpdateAndRemoveNote(t *testing.T) {
+	index := testIndex(t)
+
+	err := index.UpdateNote("standup", []Document{{Field: FieldTitle, Language: "en", Text: "Weekly planning"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := index.Search("budget", 0); len(got) != 0 {
+		t.Errorf("Replaced text is still found: %+v", got)
+	}
+	if got := noteIds(index.Search("planning", 0)); !slices.Equal(got, []string{"standup"}) {
+		t.Errorf("New text isn't found: %v", got)
+	}
+
+	if err := index.RemoveNote("standup"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := index.Search("planning", 0); len(got) != 0 {
+		t.Errorf("Removed note is still found: %+v", got)
+	}
+	if !index.IndexedAt("standup").IsZero() {
+		t.Errorf("Removed note is still indexed")
+	}
+	if len(index.data.Postings["plan"]) != 0 {
+		t.Errorf("Postings of the removed note are left: %v", index.data.Postings["plan"])
+	}
+}
+
+func TestIndexPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search", "index.json")
+	index := NewIndex(path)
+	if err := index.UpdateNote("standup", []Document{{Field: FieldBody, Language: "en", Text: "Upcoming meetings"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewIndex(path)
+	if got := noteIds(reopened.Search("meeting", 0)); !slices.Equal(got, []string{"standup"}) {
+		t.Errorf("Expected the note after reopening, got %v", got)
+	}
+	if reopened.IndexedAt("standup").IsZero() {
+		t.Errorf("Index time wasn't saved")
+	}
+}
@@ -0,0 +1,248 @@
+package search
+
+import "strings"
+
+// Porter stemmer for English, following the original description by
+// Martin Porter. b[:k+1] is the word being stemmed, j marks where the
+// currently checked ending starts
+type porterStemmer struct {
+	b []byte
+	k int
+	j int
+}
+
+func (p *porterStemmer) isConsonant(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.isConsonant(i-1)
+	}
+
+	return true
+}
+
+// Number of vowel-consonant sequences in b[:j+1]
+func (p *porterStemmer) measure() int {
+	n, i := 0, 0
+
+	for ; i <= p.j && p.isConsonant(i); i++ {
+	}
+
+	for i <= p.j {
+		for ; i <= p.j && !p.isConsonant(i); i++ {
+		}
+		if i > p.j {
+			return n
+		}
+
+		n++
+		for ; i <= p.j && p.isConsonant(i); i++ {
+		}
+	}
+
+	return n
+}
+
+func (p *porterStemmer) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.isConsonant(i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *porterStemmer) doubleConsonant(i int) bool {
+	return i >= 1 && p.b[i] == p.b[i-1] && p.isConsonant(i)
+}
+
+// Consonant-vowel-consonant ending where the last one isn't w, x or y, as in "hop"
+func (p *porterStemmer) cvc(i int) bool {
+	if i < 2 || !p.isConsonant(i) || p.isConsonant(i-1) || !p.isConsonant(i-2) {
+		return false
+	}
+
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+
+	return true
+}
+
+func (p *porterStemmer) ends(suffix string) bool {
+	if len(suffix) > p.k+1 || string(p.b[p.k+1-len(suffix):p.k+1]) != suffix {
+		return false
+	}
+
+	p.j = p.k - len(suffix)
+
+	return true
+}
+
+func (p *porterStemmer) setTo(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+func (p *porterStemmer) replace(s string) {
+	if p.measure() > 0 {
+		p.setTo(s)
+	}
+}
+
+// Plurals and -ed or -ing
+func (p *porterStemmer) step1ab() {
+	if p.b[p.k] == 's' {
+		switch {
+		case p.ends("sses"):
+			p.k -= 2
+		case p.ends("ies"):
+			p.setTo("i")
+		case p.k >= 1 && p.b[p.k-1] != 's':
+			p.k--
+		}
+	}
+
+	if p.ends("eed") {
+		if p.measure() > 0 {
+			p.k--
+		}
+		return
+	}
+
+	if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+
+		switch {
+		case p.ends("at"):
+			p.setTo("ate")
+		case p.ends("bl"):
+			p.setTo("ble")
+		case p.ends("iz"):
+			p.setTo("ize")
+		case p.doubleConsonant(p.k):
+			switch p.b[p.k] {
+			case 'l', 's', 'z':
+			default:
+				p.k--
+			}
+		default:
+			p.j = p.k
+			if p.measure() == 1 && p.cvc(p.k) {
+				p.setTo("e")
+			}
+		}
+	}
+}
+
+// Terminal y to i when there is another vowel in the stem
+func (p *porterStemmer) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+var porterStep2 = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+var porterStep3 = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+var porterStep4 = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// Double suffixes to single ones, -ization to -ize
+func (p *porterStemmer) replaceFirst(rules [][2]string) {
+	longest := -1
+
+	for i, rule := range rules {
+		if p.ends(rule[0]) && (longest < 0 || len(rule[0]) > len(rules[longest][0])) {
+			longest = i
+		}
+	}
+
+	if longest >= 0 {
+		p.ends(rules[longest][0])
+		p.replace(rules[longest][1])
+	}
+}
+
+// Drops -ant, -ence and alike when the stem is long enough
+func (p *porterStemmer) step4() {
+	longest := ""
+
+	for _, suffix := range porterStep4 {
+		if len(suffix) > len(longest) && p.ends(suffix) {
+			longest = suffix
+		}
+	}
+
+	if longest == "" {
+		return
+	}
+
+	p.ends(longest)
+	if longest == "ion" && (p.j < 0 || (p.b[p.j] != 's' && p.b[p.j] != 't')) {
+		return
+	}
+
+	if p.measure() > 1 {
+		p.k = p.j
+	}
+}
+
+// Final -e and -ll
+func (p *porterStemmer) step5() {
+	p.j = p.k
+
+	if p.b[p.k] == 'e' {
+		a := p.measure()
+		if a > 1 || (a == 1 && !p.cvc(p.k-1)) {
+			p.k--
+		}
+	}
+
+	if p.b[p.k] == 'l' && p.doubleConsonant(p.k) && p.measure() > 1 {
+		p.k--
+	}
+}
+
+func porter(word string) string {
+	word = strings.TrimSuffix(strings.TrimSuffix(word, "'s"), "'")
+
+	if len(word) <= 2 {
+		return word
+	}
+
+	for _, r := range word {
+		if r < 'a' || r > 'z' {
+			return word
+		}
+	}
+
+	p := &porterStemmer{b: []byte(word), k: len(word) - 1}
+	p.step1ab()
+
+	if p.k > 0 {
+		p.step1c()
+		p.replaceFirst(porterStep2)
+		p.replaceFirst(porterStep3)
+		p.step4()
+		p.step5()
+	}
+
+	return string(p.b[:p.k+1])
+}
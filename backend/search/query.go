@@ -0,0 +1,237 @@
+package search
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// Most matches returned for a single note
+	maxMatches = 5
+	// Snippets longer than this are cut around the first match, bytes
+	snippetLength = 200
+	// Text kept before the first match of a cut snippet, bytes
+	snippetContext = 60
+)
+
+// Matched word in a snippet, offsets are in UTF-16 code units like in
+// transcript spans, so they can be used in JS directly
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type Match struct {
+	Field       string `json:"field"`
+	RecordingId string `json:"recordingId,omitempty"`
+	// Where in the recording the matched segment is, ms
+	Start      int64       `json:"start"`
+	End        int64       `json:"end"`
+	Snippet    string      `json:"snippet"`
+	Highlights []Highlight `json:"highlights"`
+	Score      float64     `json:"score"`
+}
+
+type Result struct {
+	NoteId  string  `json:"noteId"`
+	Title   string  `json:"title"`
+	Score   float64 `json:"score"`
+	Matches []Match `json:"matches"`
+}
+
+type docHit struct {
+	id int
+	// Best score of every query word
+	scores []float64
+	terms  map[string]bool
+	score  float64
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// Cuts the text around the first matched word and marks all matched words in it
+func snippet(text, lang string, terms map[string]bool) (string, []Highlight) {
+	tokens := Analyze(text, lang)
+	matched := []Token{}
+	for _, token := range tokens {
+		if terms[token.Term] {
+			matched = append(matched, token)
+		}
+	}
+
+	start, end := 0, len(text)
+	if len(text) > snippetLength {
+		anchor := 0
+		if len(matched) > 0 {
+			anchor = max(0, matched[0].Start-snippetContext)
+		}
+
+		for _, token := range tokens {
+			if token.End > anchor {
+				start = token.Start
+				break
+			}
+		}
+
+		end = start
+		for _, token := range tokens {
+			if token.Start >= start && token.End <= start+snippetLength {
+				end = token.End
+			}
+		}
+
+		if len(matched) > 0 {
+			end = max(end, matched[0].End)
+		}
+	}
+
+	// Whitespace around isn't part of the snippet
+	for start < end && strings.ContainsRune(" \t\r\n", rune(text[start])) {
+		start++
+	}
+	for end > start && strings.ContainsRune(" \t\r\n", rune(text[end-1])) {
+		end--
+	}
+
+	// Keeps the offsets, every replaced character is a single byte
+	cut := strings.NewReplacer("\n", " ", "\r", " ", "\t", " ").Replace(text[start:end])
+
+	prefix, suffix := "", ""
+	if start > 0 && len(text) > snippetLength {
+		prefix = "… "
+	}
+	if end < len(strings.TrimRight(text, " \t\r\n")) {
+		suffix = " …"
+	}
+
+	highlights := []Highlight{}
+	offset := utf16Len(prefix)
+
+	for _, token := range matched {
+		if token.Start < start || token.End > end {
+			continue
+		}
+
+		highlights = append(highlights, Highlight{
+			Start: offset + utf16Len(cut[:token.Start-start]),
+			End:   offset + utf16Len(cut[:token.End-start]),
+		})
+	}
+
+	return prefix + cut + suffix, highlights
+}
+
+// Notes containing every word of the query, best first. Words are matched
+// by stem, so "meetings" finds "meeting"
+func (i *Index) Search(query string, limit int) []Result {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	results := []Result{}
+	terms := i.queryTerms(query)
+	if len(terms) == 0 || len(i.data.Docs) == 0 {
+		return results
+	}
+
+	hits := map[int]*docHit{}
+	for word, variants := range terms {
+		for _, term := range variants {
+			for docId := range i.data.Postings[term] {
+				hit, ok := hits[docId]
+				if !ok {
+					hit = &docHit{id: docId, scores: make([]float64, len(terms)), terms: map[string]bool{}}
+					hits[docId] = hit
+				}
+
+				hit.scores[word] = max(hit.scores[word], i.bm25(term, docId))
+				hit.terms[term] = true
+			}
+		}
+	}
+
+	perNote := map[string][]*docHit{}
+	for docId, hit := range hits {
+		doc := i.data.Docs[docId]
+		for _, score := range hit.scores {
+			hit.score += score
+		}
+		hit.score *= fieldWeights[doc.Field]
+
+		perNote[doc.NoteId] = append(perNote[doc.NoteId], hit)
+	}
+
+	for noteId, noteHits := range perNote {
+		// A note matches when each word is found somewhere in it, not
+		// necessarily in the same paragraph
+		found := make([]bool, len(terms))
+		for _, hit := range noteHits {
+			for word, score := range hit.scores {
+				found[word] = found[word] || score > 0
+			}
+		}
+
+		if slices.Contains(found, false) {
+			continue
+		}
+
+		sort.Slice(noteHits, func(a, b int) bool {
+			if noteHits[a].score != noteHits[b].score {
+				return noteHits[a].score > noteHits[b].score
+			}
+			return noteHits[a].id < noteHits[b].id
+		})
+
+		result := Result{NoteId: noteId, Title: i.noteTitle(noteId), Matches: []Match{}}
+
+		for rank, hit := range noteHits {
+			// Many matches raise the score, but less with every one of them
+			result.Score += hit.score / float64(rank+1)
+
+			if rank >= maxMatches {
+				continue
+			}
+
+			doc := i.data.Docs[hit.id]
+			text, highlights := snippet(doc.Text, doc.Language, hit.terms)
+
+			result.Matches = append(result.Matches, Match{
+				Field:       doc.Field,
+				RecordingId: doc.RecordingId,
+				Start:       doc.Start,
+				End:         doc.End,
+				Snippet:     text,
+				Highlights:  highlights,
+				Score:       hit.score,
+			})
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].NoteId < results[b].NoteId
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+func (i *Index) noteTitle(noteId string) string {
+	for _, id := range i.data.Notes[noteId].Docs {
+		if doc := i.data.Docs[id]; doc.Field == FieldTitle {
+			return doc.Text
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// Light stemmers strip the longest matching ending, words are folded first
+// so the endings have no accents. Good enough to match plural and case
+// forms, not a linguistic analysis
+var suffixes = map[string][]string{
+	"de": {"ungen", "innen", "heit", "keit", "lich", "isch", "ung", "ern", "em", "en", "er", "es", "e", "s", "n"},
+	"fr": {"issements", "issement", "atrices", "atrice", "ations", "ation", "ements", "ement", "euses", "euse", "ments", "ment", "ites", "ite", "ives", "ive", "eaux", "aux", "eux", "es", "s", "e", "x"},
+	"es": {"amientos", "imientos", "amiento", "imiento", "aciones", "acion", "mente", "idades", "idad", "ando", "iendo", "ados", "idos", "ado", "ido", "ar", "er", "ir", "as", "es", "os", "a", "e", "o", "s"},
+	"it": {"azioni", "azione", "amenti", "amento", "mente", "ando", "endo", "ata", "ato", "ate", "ati", "ito", "ita", "are", "ere", "ire", "a", "e", "i", "o"},
+	"pt": {"amentos", "amento", "acoes", "acao", "mente", "idade", "ando", "endo", "ados", "ado", "ar", "er", "ir", "as", "es", "os", "a", "e", "o", "s"},
+	"nl": {"heden", "heid", "ingen", "ing", "en", "e", "s"},
+	"ru": {
+		"ованиями", "ования", "ование", "ениями", "ениях", "ением", "ения", "ение",
+		"ами", "ями", "ого", "его", "ому", "ему", "ыми", "ими", "ться", "ешь", "ете",
+		"ует", "уют", "ать", "ять", "ить", "ая", "яя", "ое", "ее", "ые", "ие", "ой",
+		"ей", "ий", "ый", "ом", "ем", "ам", "ям", "ах", "ях", "ов", "ев", "а", "я",
+		"о", "е", "ы", "и", "у", "ю", "ь",
+	},
+	"uk": {
+		"ання", "ення", "ами", "ями", "ого", "ому", "ими", "ати", "ити", "ою", "ею",
+		"ий", "ій", "ої", "ів", "ах", "ях", "ом", "ем", "ам", "ям", "а", "я", "о",
+		"е", "и", "і", "у", "ю", "ь",
+	},
+	"pl": {"owania", "owanie", "ami", "ach", "ego", "emu", "ych", "ymi", "imi", "ow", "om", "em", "ie", "a", "e", "i", "o", "u", "y"},
+}
+
+// Shortest stem left after stripping an ending, "bus" isn't "bu"
+const minStemLength = 3
+
+func stripSuffix(word string, endings []string) string {
+	length := utf8.RuneCountInString(word)
+	longest := ""
+
+	for _, ending := range endings {
+		if len(ending) > len(longest) && strings.HasSuffix(word, ending) && length-utf8.RuneCountInString(ending) >= minStemLength {
+			longest = ending
+		}
+	}
+
+	return strings.TrimSuffix(word, longest)
+}
+
+var cyrillicLanguages = []string{"ru", "uk"}
+
+// Words in another script than the language, like Russian quotes in an
+// English note, are stemmed as the most common language of their script
+func scriptLanguage(word, lang string) string {
+	first, _ := utf8.DecodeRuneInString(word)
+	cyrillic := slices.Contains(cyrillicLanguages, lang)
+
+	switch {
+	case unicode.Is(unicode.Cyrillic, first) && !cyrillic:
+		return "ru"
+	case unicode.Is(unicode.Latin, first) && cyrillic:
+		return "en"
+	}
+
+	return lang
+}
+
+// Reduces a folded word to its stem, words of unknown languages are kept as is
+func Stem(word, lang string) string {
+	lang = scriptLanguage(word, transcript.LanguageCode(lang))
+
+	if lang == "en" {
+		return porter(word)
+	}
+
+	if endings, ok := suffixes[lang]; ok {
+		return stripSuffix(word, endings)
+	}
+
+	return word
+}
+
+// Stems a word could have in any of the languages, the query language isn't known
+func stemVariants(word string, langs []string) []string {
+	variants := []string{word}
+
+	for _, lang := range langs {
+		stem := Stem(word, lang)
+		if !slices.Contains(variants, stem) {
+			variants = append(variants, stem)
+		}
+	}
+
+	return variants
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/henmalib/whisper-notes/backend/transcript"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Word of a text, Start and End are byte offsets into it
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// Lowercases and drops accents of latin letters, "Café" and "cafe" are the
+// same word. Other scripts keep them, й isn't an и with an accent
+func fold(word string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(word) {
+		switch {
+		case r == 'ё':
+			r = 'е'
+		case r == '’':
+			r = '\''
+		case r == 'ß':
+			b.WriteString("ss")
+			continue
+		}
+
+		if r < utf8.RuneSelf || !unicode.Is(unicode.Latin, r) {
+			b.WriteRune(r)
+			continue
+		}
+
+		for _, d := range norm.NFD.String(string(r)) {
+			if !unicode.Is(unicode.Mn, d) {
+				b.WriteRune(d)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// Splits text into folded words. Apostrophes inside a word are kept, so
+// "don't" stays a single word
+func tokenize(text string) []Token {
+	tokens := []Token{}
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: fold(text[start:end]), Start: start, End: end})
+			start = -1
+		}
+	}
+
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if (r == '\'' || r == '’') && start >= 0 {
+			next, _ := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):])
+			if isWordRune(next) {
+				continue
+			}
+		}
+
+		flush(i)
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// Words of the text reduced to the terms they are indexed by
+func Analyze(text, lang string) []Token {
+	lang = transcript.LanguageCode(lang)
+	tokens := tokenize(text)
+
+	for i := range tokens {
+		tokens[i].Term = Stem(tokens[i].Term, lang)
+	}
+
+	return tokens
+}
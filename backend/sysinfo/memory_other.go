@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package sysinfo
+
+import "errors"
+
+func totalMemory() (uint64, error) {
+	return 0, errors.New("Memory detection is not supported on this platform")
+}
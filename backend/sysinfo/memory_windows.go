@@ -0,0 +1,31 @@
+package sysinfo
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var globalMemoryStatusEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+
+type memoryStatusEx struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+func totalMemory() (uint64, error) {
+	status := memoryStatusEx{}
+	status.length = uint32(unsafe.Sizeof(status))
+
+	if ret, _, err := globalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ret == 0 {
+		return 0, err
+	}
+
+	return status.totalPhys, nil
+}
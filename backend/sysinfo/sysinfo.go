@@ -0,0 +1,28 @@
+package sysinfo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+type Info struct {
+	Cores int `json:"cores"`
+	// Total physical memory in bytes, 0 when it couldn't be detected
+	Memory uint64 `json:"memory"`
+	Os     string `json:"os"`
+	Arch   string `json:"arch"`
+}
+
+func Detect() Info {
+	memory, err := totalMemory()
+	if err != nil {
+		fmt.Println("Couldn't detect total memory:", err)
+	}
+
+	return Info{
+		Cores:  runtime.NumCPU(),
+		Memory: memory,
+		Os:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+	}
+}
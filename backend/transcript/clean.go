@@ -0,0 +1,225 @@
+package transcript
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Stages applied to the verbatim text, each can be turned off
+type CleanOptions struct {
+	RemoveFillers bool `json:"removeFillers"`
+	// Start a new paragraph on long pauses and speaker changes
+	Paragraphs       bool  `json:"paragraphs"`
+	ParagraphPauseMs int64 `json:"paragraphPauseMs"`
+	FixCasing        bool  `json:"fixCasing"`
+	// Only English numbers are understood for now
+	NormalizeNumbers bool `json:"normalizeNumbers"`
+}
+
+// Phrases with several words are only removed when set off by a comma,
+// "you know, it works" loses it, "do you know him" doesn't
+var fillerPhrases = map[string][]string{
+	"en": {"um", "umm", "uh", "uhh", "uhm", "erm", "er", "ah", "hmm", "mm", "you know"},
+	"de": {"äh", "ähm", "öh", "öhm", "hm", "hmm"},
+	"fr": {"euh", "heu", "bah", "hum"},
+	"es": {"eh", "em", "ehm", "mmm"},
+	"it": {"ehm", "uhm", "mmm"},
+	"pt": {"hum", "ahn"},
+	"ru": {"э", "эм", "ээ", "мм", "как бы"},
+	"uk": {"е", "ем", "ее", "мм"},
+	"pl": {"yyy", "yy", "eee", "hmm"},
+	"nl": {"eh", "ehm", "uh", "uhm"},
+}
+
+func isEnglish(lang string) bool {
+	return lang == "en" || lang == "english"
+}
+
+func isSentenceEnd(word string) bool {
+	word = strings.TrimRight(word, `"')]»”’`)
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "?") ||
+		strings.HasSuffix(word, "!") || strings.HasSuffix(word, "…")
+}
+
+func trailingPunct(word string) string {
+	return word[len(strings.TrimRightFunc(word, unicode.IsPunct)):]
+}
+
+// Whisper sometimes emits punctuation as a separate token, " ," or " ?"
+func attachPunctuation(words []string) []string {
+	out := []string{}
+
+	for _, word := range words {
+		first, _ := utf8.DecodeRuneInString(word)
+		isPunct := strings.TrimFunc(word, unicode.IsPunct) == ""
+
+		if isPunct && len(out) > 0 && !strings.ContainsRune(`([{"'¿¡«“‘-`, first) {
+			out[len(out)-1] += word
+			continue
+		}
+
+		out = append(out, word)
+	}
+
+	return out
+}
+
+func matchFiller(words []string, i int, phrases []string, out []string) int {
+	longest := 0
+
+	for _, phrase := range phrases {
+		parts := strings.Fields(phrase)
+		n := len(parts)
+		if n <= longest || i+n > len(words) {
+			continue
+		}
+
+		matches := true
+		for k, part := range parts {
+			if normalizeWord(words[i+k]) != part {
+				matches = false
+				break
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		if n > 1 {
+			commaAfter := strings.HasSuffix(words[i+n-1], ",")
+			commaBefore := len(out) > 0 && strings.HasSuffix(out[len(out)-1], ",")
+			if !commaAfter && !commaBefore {
+				continue
+			}
+		}
+
+		longest = n
+	}
+
+	return longest
+}
+
+func removeFillers(words []string, lang string) []string {
+	phrases := fillerPhrases[lang]
+	if len(phrases) == 0 {
+		return words
+	}
+
+	out := []string{}
+	for i := 0; i < len(words); {
+		n := matchFiller(words, i, phrases, out)
+		if n == 0 {
+			out = append(out, words[i])
+			i++
+			continue
+		}
+
+		// "so it works, um." keeps the sentence end, a hesitating "um..." doesn't
+		end := strings.ReplaceAll(trailingPunct(words[i+n-1]), ",", "")
+		if end != "" && end != "..." && end != "…" && len(out) > 0 {
+			out[len(out)-1] = strings.TrimRight(out[len(out)-1], ",") + end
+		}
+
+		i += n
+	}
+
+	return out
+}
+
+func capitalize(word string) string {
+	for i, r := range word {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		if unicode.IsLower(r) {
+			return word[:i] + string(unicode.ToUpper(r)) + word[i+utf8.RuneLen(r):]
+		}
+
+		return word
+	}
+
+	return word
+}
+
+func fixCasing(words []string, lang string) []string {
+	sentenceStart := true
+
+	for i, word := range words {
+		if isEnglish(lang) {
+			core := strings.TrimFunc(word, unicode.IsPunct)
+			if core == "i" || strings.HasPrefix(core, "i'") || strings.HasPrefix(core, "i’") {
+				word = capitalize(word)
+			}
+		}
+
+		if sentenceStart {
+			word = capitalize(word)
+		}
+
+		words[i] = word
+		sentenceStart = isSentenceEnd(word)
+	}
+
+	return words
+}
+
+// Readable version of the transcript. Fillers are removed per segment, so
+// segments in other languages use their own lists
+func (t *Transcript) CleanText(opts CleanOptions) string {
+	segments := t.Segments
+	if len(segments) == 0 {
+		segments = []Segment{{Text: t.Text}}
+	}
+
+	paragraphs := [][]string{}
+	langs := []string{}
+
+	for i, segment := range segments {
+		lang := segment.Language
+		if lang == "" {
+			lang = t.Language
+		}
+
+		words := attachPunctuation(strings.Fields(segment.Text))
+		if opts.RemoveFillers {
+			words = removeFillers(words, lang)
+		}
+
+		newParagraph := i == 0
+		if i > 0 && opts.Paragraphs {
+			prev := segments[i-1]
+			pause := opts.ParagraphPauseMs > 0 && segment.Start-prev.End >= opts.ParagraphPauseMs
+			newParagraph = pause || segment.Speaker != prev.Speaker
+		}
+
+		if newParagraph {
+			paragraphs = append(paragraphs, []string{})
+			langs = append(langs, lang)
+		}
+
+		last := len(paragraphs) - 1
+		paragraphs[last] = append(paragraphs[last], words...)
+	}
+
+	texts := []string{}
+	for i, words := range paragraphs {
+		words = attachPunctuation(words)
+
+		if opts.NormalizeNumbers && isEnglish(langs[i]) {
+			words = normalizeNumbers(words)
+		}
+
+		if opts.FixCasing {
+			words = fixCasing(words, langs[i])
+		}
+
+		if len(words) > 0 {
+			texts = append(texts, strings.Join(words, " "))
+		}
+	}
+
+	return strings.Join(texts, "\n\n")
+}
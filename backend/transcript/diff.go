@@ -0,0 +1,205 @@
+package transcript
+
+import (
+	"strings"
+	"time"
+)
+
+// Unrelated texts, e.g. transcripts in different languages, take quadratic
+// time. After this the rest is shown as replaced, same as diff-match-patch does
+const diffTimeout = time.Second
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type differ struct {
+	a, b     []string
+	deleted  []bool
+	inserted []bool
+	deadline time.Time
+}
+
+// Word by word difference between two texts, neighbouring words with the
+// same operation are merged. Uses Myers' algorithm in linear space, so long
+// transcripts don't need a quadratic table
+func DiffWords(from, to string) []DiffOp {
+	d := &differ{
+		a:        strings.Fields(from),
+		b:        strings.Fields(to),
+		deadline: time.Now().Add(diffTimeout),
+	}
+	d.deleted = make([]bool, len(d.a))
+	d.inserted = make([]bool, len(d.b))
+	d.compare(0, len(d.a), 0, len(d.b))
+
+	ops := []DiffOp{}
+	add := func(op, word string) {
+		if last := len(ops) - 1; last >= 0 && ops[last].Op == op {
+			ops[last].Text += " " + word
+			return
+		}
+
+		ops = append(ops, DiffOp{Op: op, Text: word})
+	}
+
+	i, j := 0, 0
+	for i < len(d.a) || j < len(d.b) {
+		switch {
+		case i < len(d.a) && d.deleted[i]:
+			add(DiffDelete, d.a[i])
+			i++
+		case j < len(d.b) && d.inserted[j]:
+			add(DiffInsert, d.b[j])
+			j++
+		default:
+			add(DiffEqual, d.a[i])
+			i++
+			j++
+		}
+	}
+
+	return ops
+}
+
+// Marks deleted and inserted words of a[aLo:aHi] against b[bLo:bHi]
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		x, y, ok := bisect(d.a[aLo:aHi], d.b[bLo:bHi], d.deadline)
+		if !ok {
+			for i := aLo; i < aHi; i++ {
+				d.deleted[i] = true
+			}
+			for j := bLo; j < bHi; j++ {
+				d.inserted[j] = true
+			}
+			return
+		}
+
+		d.compare(aLo, aLo+x, bLo, bLo+y)
+		d.compare(aLo+x, aHi, bLo+y, bHi)
+	}
+}
+
+// Finds the middle snake, the point where the shortest edit path can be
+// split in two. Searches from both ends until the paths meet, gives up
+// after the deadline
+func bisect(a, b []string, deadline time.Time) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// Two extra cells, the first step reads one past the last diagonal
+	length := 2*maxD + 2
+
+	v1 := make([]int, length)
+	v2 := make([]int, length)
+	for i := range length {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[offset+1] = 0
+	v2[offset+1] = 0
+
+	delta := n - m
+	// Paths meet in the forward pass when delta is odd
+	front := delta%2 != 0
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+
+	for step := range maxD {
+		if time.Now().After(deadline) {
+			break
+		}
+
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1offset := offset + k1
+
+			var x1 int
+			if k1 == -step || (k1 != step && v1[k1offset-1] < v1[k1offset+1]) {
+				x1 = v1[k1offset+1]
+			} else {
+				x1 = v1[k1offset-1] + 1
+			}
+
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[k1offset] = x1
+
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				k2offset := offset + delta - k1
+				if k2offset >= 0 && k2offset < length && v2[k2offset] != -1 && x1 >= n-v2[k2offset] {
+					return x1, y1, true
+				}
+			}
+		}
+
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2offset := offset + k2
+
+			var x2 int
+			if k2 == -step || (k2 != step && v2[k2offset-1] < v2[k2offset+1]) {
+				x2 = v2[k2offset+1]
+			} else {
+				x2 = v2[k2offset-1] + 1
+			}
+
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2offset] = x2
+
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1offset := offset + delta - k2
+				if k1offset >= 0 && k1offset < length && v1[k1offset] != -1 {
+					x1 := v1[k1offset]
+					y1 := offset + x1 - k1offset
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
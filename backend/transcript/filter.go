@@ -0,0 +1,268 @@
+package transcript
+
+import (
+	"bytes"
+	"compress/zlib"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Reasons a segment was flagged as hallucinated
+const (
+	FlagRepetition  = "repetition"
+	FlagPhantom     = "phantom"
+	FlagSilence     = "silence"
+	FlagCompression = "compression"
+)
+
+const (
+	// Same segment said this many times in a row is a loop, not a conversation
+	maxSegmentRepeats = 3
+	// Longest phrase checked for looping inside a segment
+	maxNgram = 6
+	// Whisper itself treats text compressing better than this as repetitive
+	maxCompressionRatio = 2.4
+	// Loudest 20ms window of a segment quieter than this is silence, about -40 dBFS
+	silenceLevel   = 0.01
+	silenceWindows = 50
+)
+
+// Lines whisper learned from subtitles, it produces them on silence and music.
+// Only phrases nobody says during a meeting are listed
+var phantomPhrases = map[string][]string{
+	"en": {
+		"thank you for watching",
+		"thanks for watching",
+		"please subscribe",
+		"like and subscribe",
+		"subtitles by the amara.org community",
+		"transcription by castingwords",
+	},
+	"de": {
+		"untertitel im auftrag des zdf",
+		"untertitel der amara.org-community",
+		"vielen dank fürs zuschauen",
+	},
+	"fr": {
+		"sous-titres réalisés par la communauté d'amara.org",
+		"merci d'avoir regardé",
+	},
+	"es": {
+		"subtítulos realizados por la comunidad de amara.org",
+		"gracias por ver el video",
+	},
+	"it": {
+		"sottotitoli creati dalla comunità amara.org",
+		"grazie per la visione",
+	},
+	"pt": {
+		"legendas pela comunidade amara.org",
+		"obrigado por assistir",
+	},
+	"ru": {
+		"субтитры сделал dimatorzok",
+		"продолжение следует",
+		"спасибо за просмотр",
+		"редактор субтитров",
+	},
+	"uk": {
+		"дякую за перегляд",
+		"субтитри зроблені спільнотою amara.org",
+	},
+	"pl": {
+		"napisy stworzone przez społeczność amara.org",
+		"dziękuję za obejrzenie",
+	},
+	"nl": {
+		"ondertiteling door de amara.org gemeenschap",
+		"bedankt voor het kijken",
+	},
+}
+
+type FilterOptions struct {
+	// Drop flagged segments instead of only flagging them
+	Remove bool
+	// Recording the transcript was made from, silence isn't checked without it
+	Audio      []float32
+	SampleRate int
+}
+
+func normalizeText(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = normalizeWord(word)
+	}
+
+	return strings.Join(words, " ")
+}
+
+func isPhantom(text, lang string) bool {
+	normalized := normalizeText(text)
+	if normalized == "" {
+		return false
+	}
+
+	phrases, ok := phantomPhrases[lang]
+	if !ok {
+		// Unknown language, check every list
+		for _, list := range phantomPhrases {
+			phrases = append(phrases, list...)
+		}
+	}
+
+	for _, phrase := range phrases {
+		if strings.Contains(normalized, normalizeText(phrase)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Some n-gram repeated back to back, "I I I I I I" or "go on, go on, go on, go on"
+func hasRepeatedNgram(text string) bool {
+	words := strings.Fields(normalizeText(text))
+
+	for n := 1; n <= maxNgram; n++ {
+		// Single words get repeated for real more often than phrases
+		repeats := 4
+		if n == 1 {
+			repeats = 6
+		}
+
+		for start := 0; start+n*repeats <= len(words); start++ {
+			count := 1
+			for next := start + n; next+n <= len(words); next += n {
+				if !slices.Equal(words[start:start+n], words[next:next+n]) {
+					break
+				}
+				count++
+			}
+
+			if count >= repeats {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func compressionRatio(text string) float64 {
+	if text == "" {
+		return 0
+	}
+
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write([]byte(text))
+	writer.Close()
+
+	return float64(len(text)) / float64(buf.Len())
+}
+
+func isSilent(segment Segment, opts FilterOptions) bool {
+	if len(opts.Audio) == 0 || opts.SampleRate <= 0 {
+		return false
+	}
+
+	start := int(segment.Start * int64(opts.SampleRate) / 1000)
+	end := min(int(segment.End*int64(opts.SampleRate)/1000), len(opts.Audio))
+	if start >= end {
+		return false
+	}
+
+	window := max(opts.SampleRate/silenceWindows, 1)
+	loudest := 0.0
+
+	for pos := start; pos < end; pos += window {
+		sum := 0.0
+		samples := opts.Audio[pos:min(pos+window, end)]
+		for _, v := range samples {
+			sum += float64(v) * float64(v)
+		}
+
+		loudest = max(loudest, math.Sqrt(sum/float64(len(samples))))
+	}
+
+	return loudest < silenceLevel
+}
+
+// Flags segments that look like whisper hallucinations: loops, subtitle
+// credits, text over silence and overly repetitive text. With Remove the
+// flagged segments are dropped and the unfiltered transcript is kept in Original
+func (t *Transcript) Filter(opts FilterOptions) *Transcript {
+	result := *t
+	result.Segments = make([]Segment, len(t.Segments))
+	copy(result.Segments, t.Segments)
+
+	flagged := false
+	run := 0
+
+	for i := range result.Segments {
+		segment := &result.Segments[i]
+		segment.Flags = nil
+
+		lang := segment.Language
+		if lang == "" {
+			lang = t.Language
+		}
+
+		if i > 0 && normalizeText(segment.Text) == normalizeText(result.Segments[i-1].Text) {
+			run++
+		} else {
+			run = 1
+		}
+
+		if run >= maxSegmentRepeats || hasRepeatedNgram(segment.Text) {
+			segment.Flags = append(segment.Flags, FlagRepetition)
+		}
+
+		// Flag the whole loop, keeping the first one
+		if run == maxSegmentRepeats {
+			for j := i - run + 2; j < i; j++ {
+				result.Segments[j].Flags = append(result.Segments[j].Flags, FlagRepetition)
+			}
+		}
+
+		if isPhantom(segment.Text, lang) {
+			segment.Flags = append(segment.Flags, FlagPhantom)
+		}
+
+		if isSilent(*segment, opts) {
+			segment.Flags = append(segment.Flags, FlagSilence)
+		}
+
+		if compressionRatio(segment.Text) > maxCompressionRatio {
+			segment.Flags = append(segment.Flags, FlagCompression)
+		}
+
+		flagged = flagged || len(segment.Flags) > 0
+	}
+
+	if !opts.Remove || !flagged {
+		return &result
+	}
+
+	original := result
+	kept := []Segment{}
+	texts := []string{}
+
+	for _, segment := range result.Segments {
+		if len(segment.Flags) > 0 {
+			continue
+		}
+
+		kept = append(kept, segment)
+		if segment.Text != "" {
+			texts = append(texts, segment.Text)
+		}
+	}
+
+	result.Segments = kept
+	result.Text = strings.Join(texts, " ")
+	result.Original = &original
+
+	return &result
+}
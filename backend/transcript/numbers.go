@@ -0,0 +1,359 @@
+package transcript
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var unitWords = map[string]int64{
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4,
+	"five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+}
+
+var teenWords = map[string]int64{
+	"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14,
+	"fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+}
+
+var tensWords = map[string]int64{
+	"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+	"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+}
+
+var scaleWords = map[string]int64{
+	"thousand": 1_000, "million": 1_000_000, "billion": 1_000_000_000,
+}
+
+var ordinalWords = map[string]int64{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+	"sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+	"eleventh": 11, "twelfth": 12, "thirteenth": 13, "fourteenth": 14, "fifteenth": 15,
+	"sixteenth": 16, "seventeenth": 17, "eighteenth": 18, "nineteenth": 19,
+	"twentieth": 20, "thirtieth": 30,
+}
+
+var monthNames = []string{
+	"January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December",
+}
+
+type numToken struct {
+	lead  string
+	raw   string
+	trail string
+	// Lowercased raw
+	word string
+	// Was glued to the next token with a hyphen, "twenty-five"
+	hyphen bool
+}
+
+func isNumberWord(word string) bool {
+	_, unit := unitWords[word]
+	_, teen := teenWords[word]
+	_, tens := tensWords[word]
+	_, scale := scaleWords[word]
+	_, ordinal := ordinalWords[word]
+
+	return unit || teen || tens || scale || ordinal || word == "hundred"
+}
+
+func splitNumTokens(words []string) []numToken {
+	tokens := []numToken{}
+
+	for _, word := range words {
+		core := strings.TrimFunc(word, unicode.IsPunct)
+		start := strings.Index(word, core)
+		if core == "" {
+			start = len(word)
+		}
+		lead, trail := word[:start], word[start+len(core):]
+
+		parts := strings.Split(core, "-")
+		split := len(parts) > 1
+		for _, part := range parts {
+			split = split && isNumberWord(strings.ToLower(part))
+		}
+
+		if !split {
+			parts = []string{core}
+		}
+
+		for i, part := range parts {
+			token := numToken{raw: part, word: strings.ToLower(part), hyphen: i < len(parts)-1}
+			if i == 0 {
+				token.lead = lead
+			}
+			if i == len(parts)-1 {
+				token.trail = trail
+			}
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
+
+// Tokens in the middle of a number can't have punctuation around them
+func joinable(tokens []numToken, i, start int) bool {
+	return i < len(tokens) && (i == start || tokens[i].lead == "") && (i == start || tokens[i-1].trail == "")
+}
+
+// "three hundred and twenty five thousand" and alike
+func parseCardinal(tokens []numToken, start int) (int64, int) {
+	var total, current int64
+	last := ""
+	i := start
+
+	for joinable(tokens, i, start) {
+		word := tokens[i].word
+
+		if word == "and" && (last == "hundred" || last == "scale") && joinable(tokens, i+1, start) {
+			next := tokens[i+1].word
+			if _, ok := unitWords[next]; ok {
+				i++
+				continue
+			}
+			if _, ok := teenWords[next]; ok {
+				i++
+				continue
+			}
+			if _, ok := tensWords[next]; ok {
+				i++
+				continue
+			}
+		}
+
+		if v, ok := unitWords[word]; ok && (last == "" || last == "tens" || last == "hundred" || last == "scale") {
+			current += v
+			last = "unit"
+		} else if v, ok := teenWords[word]; ok && (last == "" || last == "hundred" || last == "scale") {
+			current += v
+			last = "teen"
+		} else if v, ok := tensWords[word]; ok && (last == "" || last == "hundred" || last == "scale") {
+			current += v
+			last = "tens"
+		} else if word == "hundred" && (last == "unit" || last == "teen") {
+			current *= 100
+			last = "hundred"
+		} else if v, ok := scaleWords[word]; ok && last != "" && last != "scale" {
+			total += current * v
+			current = 0
+			last = "scale"
+		} else {
+			break
+		}
+
+		i++
+	}
+
+	// Trailing "and" was only consumed when a number followed it
+	if i > start && tokens[i-1].word == "and" {
+		i--
+	}
+
+	return total + current, i - start
+}
+
+// Two digit group of a spoken year, "nineteen" or "ninety nine"
+func parseTwoDigits(tokens []numToken, start int) (int64, int) {
+	if !joinable(tokens, start, start) {
+		return 0, 0
+	}
+
+	if v, ok := teenWords[tokens[start].word]; ok {
+		return v, 1
+	}
+
+	v, ok := tensWords[tokens[start].word]
+	if !ok {
+		return 0, 0
+	}
+
+	if joinable(tokens, start+1, start) {
+		if unit, ok := unitWords[tokens[start+1].word]; ok && unit > 0 {
+			return v + unit, 2
+		}
+	}
+
+	return v, 1
+}
+
+// "nineteen ninety nine", "twenty twenty four", "nineteen oh five"
+func parseYear(tokens []numToken, start int) (int64, int) {
+	century, n := parseTwoDigits(tokens, start)
+	if n == 0 || (century != 19 && century != 20) {
+		return 0, 0
+	}
+
+	i := start + n
+	if !joinable(tokens, i, start) {
+		return 0, 0
+	}
+
+	if tokens[i].word == "hundred" {
+		return century * 100, n + 1
+	}
+
+	if tokens[i].word == "oh" && joinable(tokens, i+1, start) {
+		if unit, ok := unitWords[tokens[i+1].word]; ok {
+			return century*100 + unit, n + 2
+		}
+	}
+
+	year, yearN := parseTwoDigits(tokens, i)
+	if yearN == 0 {
+		return 0, 0
+	}
+
+	return century*100 + year, n + yearN
+}
+
+// "twenty first", "third" or plain "twelve"
+func parseDay(tokens []numToken, start int) (int64, int) {
+	if !joinable(tokens, start, start) {
+		return 0, 0
+	}
+
+	if v, ok := ordinalWords[tokens[start].word]; ok {
+		return v, 1
+	}
+
+	if tens, ok := tensWords[tokens[start].word]; ok && joinable(tokens, start+1, start) {
+		if v, ok := ordinalWords[tokens[start+1].word]; ok && v < 10 {
+			return tens + v, 2
+		}
+	}
+
+	day, n := parseCardinal(tokens, start)
+	if n == 0 || day < 1 || day > 31 {
+		return 0, 0
+	}
+
+	return day, n
+}
+
+func monthAt(token numToken) string {
+	for _, month := range monthNames {
+		// "may" is a verb more often than not, whisper capitalizes months
+		if token.raw == month {
+			return month
+		}
+	}
+
+	return ""
+}
+
+// "March twenty first twenty twenty four" becomes "March 21, 2024"
+func parseDate(tokens []numToken, start int) (string, int) {
+	month := monthAt(tokens[start])
+	if month == "" || tokens[start].trail != "" {
+		return "", 0
+	}
+
+	day, n := parseDay(tokens, start+1)
+	if n == 0 {
+		return "", 0
+	}
+
+	text := month + " " + strconv.FormatInt(day, 10)
+	i := start + 1 + n
+
+	if tokens[i-1].trail == "" && i < len(tokens) && tokens[i].lead == "" {
+		if year, yearN := parseYear(tokens, i); yearN > 0 {
+			return text + ", " + strconv.FormatInt(year, 10), i + yearN - start
+		}
+	}
+
+	return text, i - start
+}
+
+// Spoken number with optional decimals and percent sign
+func parseNumber(tokens []numToken, start int) (string, int) {
+	value, n := parseYear(tokens, start)
+	if n == 0 {
+		value, n = parseCardinal(tokens, start)
+	}
+
+	if n == 0 {
+		return "", 0
+	}
+
+	text := strconv.FormatInt(value, 10)
+	i := start + n
+	spoken := n > 1 || value >= 10
+
+	if joinable(tokens, i, start) && tokens[i].word == "point" {
+		digits := ""
+		for j := i + 1; joinable(tokens, j, start); j++ {
+			word := tokens[j].word
+			if word == "oh" {
+				word = "zero"
+			}
+
+			unit, ok := unitWords[word]
+			if !ok {
+				break
+			}
+			digits += strconv.FormatInt(unit, 10)
+		}
+
+		if digits != "" {
+			text += "." + digits
+			i += 1 + len(digits)
+			spoken = true
+		}
+	}
+
+	if joinable(tokens, i, start) && tokens[i].word == "percent" {
+		text += "%"
+		i++
+		spoken = true
+	} else if joinable(tokens, i+1, start) && tokens[i].word == "per" && tokens[i+1].word == "cent" {
+		text += "%"
+		i += 2
+		spoken = true
+	}
+
+	// Small numbers read better as words, "one question"
+	if !spoken {
+		return "", 0
+	}
+
+	return text, i - start
+}
+
+// Replaces spoken English numbers and dates with digits
+func normalizeNumbers(words []string) []string {
+	tokens := splitNumTokens(words)
+	out := []string{}
+	glue := false
+
+	emit := func(text string, hyphen bool) {
+		if glue && len(out) > 0 {
+			out[len(out)-1] += "-" + text
+		} else {
+			out = append(out, text)
+		}
+		glue = hyphen
+	}
+
+	for i := 0; i < len(tokens); {
+		text, n := parseDate(tokens, i)
+		if n == 0 {
+			text, n = parseNumber(tokens, i)
+		}
+
+		if n == 0 {
+			emit(tokens[i].lead+tokens[i].raw+tokens[i].trail, tokens[i].hyphen)
+			i++
+			continue
+		}
+
+		last := tokens[i+n-1]
+		emit(tokens[i].lead+text+last.trail, last.hyphen)
+		i += n
+	}
+
+	return out
+}
@@ -0,0 +1,148 @@
+package transcript
+
+import (
+	"strings"
+	"unicode"
+)
+
+// How many words at the chunk border are compared when looking for duplicates
+const maxOverlapWords = 12
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	}))
+}
+
+// Length of the longest suffix of prev that matches a prefix of next
+func overlapLength(prev, next []string) int {
+	for n := min(len(prev), len(next), maxOverlapWords); n > 0; n-- {
+		matches := true
+		for i := range n {
+			if normalizeWord(prev[len(prev)-n+i]) != normalizeWord(next[i]) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return n
+		}
+	}
+
+	return 0
+}
+
+// Words of the segment compared across chunk borders. Segments without word
+// timings fall back to their text split on spaces
+func segmentWords(segment Segment) []string {
+	if len(segment.Words) == 0 {
+		return strings.Fields(segment.Text)
+	}
+
+	words := []string{}
+	for _, word := range segment.Words {
+		words = append(words, word.Text)
+	}
+
+	return words
+}
+
+// Byte offset of words[n] in the text, the words are found in order. When
+// it's missing, the offset right after the last dropped word found
+func wordOffset(text string, words []Word, n int) int {
+	cursor := 0
+	for i, word := range words[:n+1] {
+		j := indexWord(text[cursor:], word.Text, len(text))
+		if j < 0 {
+			continue
+		}
+
+		if i == n {
+			return cursor + j
+		}
+
+		cursor += j + len(word.Text)
+	}
+
+	return cursor
+}
+
+// Drops first n words of the segments, removing segments that end up empty.
+// Text is cut where the first kept word starts, words don't always match
+// spaces in it: punctuation, languages written without them
+func dropLeadingWords(segments []Segment, n int) []Segment {
+	for n > 0 && len(segments) > 0 {
+		segment := &segments[0]
+
+		words := segmentWords(*segment)
+		if len(words) <= n {
+			n -= len(words)
+			segments = segments[1:]
+			continue
+		}
+
+		if len(segment.Words) == 0 {
+			segment.Text = strings.Join(words[n:], " ")
+		} else {
+			segment.Text = strings.TrimSpace(segment.Text[wordOffset(segment.Text, segment.Words, n):])
+			segment.Words = segment.Words[n:]
+			segment.Start = segment.Words[0].Start
+		}
+		n = 0
+	}
+
+	return segments
+}
+
+// Appends transcript of a chunk that overlaps with the already stitched
+// audio. Segments that end before `from` were transcribed by the previous
+// chunk, words repeated across the border are removed by comparing text
+func (t *Transcript) Stitch(next *Transcript, from int64) {
+	segments := []Segment{}
+	for _, segment := range next.Segments {
+		if segment.End > from {
+			segments = append(segments, segment)
+		}
+	}
+
+	head := []string{}
+	for _, segment := range segments {
+		head = append(head, segmentWords(segment)...)
+		if len(head) >= maxOverlapWords {
+			break
+		}
+	}
+
+	tail := []string{}
+	for i := len(t.Segments) - 1; i >= 0 && len(tail) < maxOverlapWords; i-- {
+		tail = append(segmentWords(t.Segments[i]), tail...)
+	}
+
+	segments = dropLeadingWords(segments, overlapLength(tail, head))
+
+	texts := []string{}
+	if t.Text != "" {
+		texts = append(texts, t.Text)
+	}
+
+	for _, segment := range segments {
+		if segment.Text != "" {
+			texts = append(texts, segment.Text)
+		}
+	}
+
+	t.Segments = append(t.Segments, segments...)
+	t.Text = strings.Join(texts, " ")
+
+	if t.Model == "" {
+		t.Model = next.Model
+	}
+
+	if t.Language == "" {
+		t.Language = next.Language
+		t.LanguageProbability = next.LanguageProbability
+	}
+
+	t.Languages = append(t.Languages, next.Languages...)
+}
@@ -0,0 +1,149 @@
+package transcript
+
+import (
+	"strings"
+	"testing"
+)
+
+// Segment with a word per text, timed one after another from start
+func timedSegment(start int64, texts ...string) Segment {
+	words := []Word{}
+	for i, text := range texts {
+		at := start + int64(i*100)
+		words = append(words, Word{Text: text, Start: at, End: at + 90})
+	}
+
+	return Segment{
+		Start: start,
+		End:   start + int64(len(texts)*100),
+		Text:  strings.Join(texts, " "),
+		Words: words,
+	}
+}
+
+func stitched(segments ...Segment) *Transcript {
+	texts := []string{}
+	for _, segment := range segments {
+		texts = append(texts, strings.TrimSpace(segment.Text))
+	}
+
+	return &Transcript{Text: strings.Join(texts, " "), Segments: segments}
+}
+
+func TestStitch(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     *Transcript
+		next     *Transcript
+		from     int64
+		wantText string
+		// Words left in the first segment of the next chunk
+		wantWords int
+	}{
+		{
+			name:      "repeated words are dropped",
+			prev:      stitched(timedSegment(0, "so", "we", "ship", "it.")),
+			next:      stitched(timedSegment(200, "ship", "it.", "Next", "topic.")),
+			from:      200,
+			wantText:  "so we ship it. Next topic.",
+			wantWords: 2,
+		},
+		{
+			name:      "punctuation differs across the border",
+			prev:      stitched(timedSegment(0, "let's", "wrap", "up,")),
+			next:      stitched(timedSegment(100, "wrap", "up.", "Thanks", "everyone.")),
+			from:      100,
+			wantText:  "let's wrap up, Thanks everyone.",
+			wantWords: 2,
+		},
+		{
+			// Whisper sticks the dash to the word before it, the text has it apart
+			name: "punctuation separated by spaces",
+			prev: &Transcript{Text: "we wrap up — thanks", Segments: []Segment{{
+				Text:  "we wrap up — thanks",
+				Words: []Word{{Text: "we"}, {Text: "wrap"}, {Text: "up—"}, {Text: "thanks"}},
+			}}},
+			next: &Transcript{Text: "up — thanks everyone", Segments: []Segment{{
+				Start: 200,
+				End:   500,
+				Text:  "up — thanks everyone",
+				Words: []Word{{Text: "up—", Start: 200}, {Text: "thanks", Start: 300}, {Text: "everyone", Start: 400}},
+			}}},
+			from:      200,
+			wantText:  "we wrap up — thanks everyone",
+			wantWords: 1,
+		},
+		{
+			name: "words not separated by spaces",
+			prev: &Transcript{Text: "今日は晴れです", Segments: []Segment{{
+				Text:  "今日は晴れです",
+				Words: []Word{{Text: "今日"}, {Text: "は"}, {Text: "晴れ"}, {Text: "です"}},
+			}}},
+			next: &Transcript{Text: "晴れです。明日も", Segments: []Segment{{
+				Start: 200,
+				End:   600,
+				Text:  "晴れです。明日も",
+				Words: []Word{{Text: "晴れ", Start: 200}, {Text: "です。", Start: 300}, {Text: "明日", Start: 400}, {Text: "も", Start: 500}},
+			}}},
+			from:      200,
+			wantText:  "今日は晴れです 明日も",
+			wantWords: 2,
+		},
+		{
+			name:      "whole overlapping segment is dropped",
+			prev:      stitched(timedSegment(0, "one", "two", "three")),
+			next:      stitched(timedSegment(100, "two", "three"), timedSegment(300, "four", "five")),
+			from:      100,
+			wantText:  "one two three four five",
+			wantWords: 2,
+		},
+		{
+			name:      "segments of the previous chunk are skipped",
+			prev:      stitched(timedSegment(0, "first", "part")),
+			next:      stitched(timedSegment(0, "first"), timedSegment(200, "second", "part")),
+			from:      150,
+			wantText:  "first part second part",
+			wantWords: 2,
+		},
+		{
+			name:      "nothing repeated",
+			prev:      stitched(timedSegment(0, "good", "morning")),
+			next:      stitched(timedSegment(200, "how", "are", "you")),
+			from:      200,
+			wantText:  "good morning how are you",
+			wantWords: 3,
+		},
+		{
+			name:      "segments without word timings",
+			prev:      stitched(Segment{Text: "see you on monday"}),
+			next:      stitched(Segment{End: 500, Text: "on monday then"}),
+			from:      0,
+			wantText:  "see you on monday then",
+			wantWords: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prevSegments := len(test.prev.Segments)
+			test.prev.Stitch(test.next, test.from)
+
+			if test.prev.Text != test.wantText {
+				t.Errorf("Expected text %q, got %q", test.wantText, test.prev.Text)
+			}
+
+			if len(test.prev.Segments) <= prevSegments {
+				t.Fatalf("No segments of the next chunk were kept")
+			}
+
+			first := test.prev.Segments[prevSegments]
+			if len(first.Words) != test.wantWords {
+				t.Errorf("Expected %d words in the first stitched segment, got %+v", test.wantWords, first.Words)
+			}
+
+			if len(first.Words) > 0 && first.Start != first.Words[0].Start {
+				t.Errorf("Segment starts at %d, its first word at %d", first.Start, first.Words[0].Start)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package transcript
+
+import "sort"
+
+// Word found by time or text offset. Offsets point into DisplayText and are
+// in UTF-16 code units, same as in Span
+type WordMatch struct {
+	Word      Word `json:"word"`
+	Index     int  `json:"index"`
+	TextStart int  `json:"textStart"`
+	TextEnd   int  `json:"textEnd"`
+}
+
+func (p wordPosition) match(index int) *WordMatch {
+	return &WordMatch{
+		Word:      p.word,
+		Index:     index,
+		TextStart: p.start,
+		TextEnd:   p.end,
+	}
+}
+
+// Word being said at ms. In a pause the last said word stays current, so
+// highlighting doesn't blink between words. Nil before the first word
+func (t *Transcript) WordAt(ms int64) *WordMatch {
+	positions := t.wordPositions()
+
+	i := sort.Search(len(positions), func(i int) bool {
+		return positions[i].word.Start > ms
+	}) - 1
+
+	if i < 0 {
+		return nil
+	}
+
+	return positions[i].match(i)
+}
+
+// Word at the text offset, or the next one when offset is between words.
+// Nil past the last word
+func (t *Transcript) WordAtOffset(offset int) *WordMatch {
+	positions := t.wordPositions()
+
+	i := sort.Search(len(positions), func(i int) bool {
+		return positions[i].end > offset
+	})
+
+	if i == len(positions) {
+		return nil
+	}
+
+	return positions[i].match(i)
+}
@@ -0,0 +1,101 @@
+package transcript
+
+import (
+	"slices"
+	"strings"
+)
+
+// Times are in milliseconds from the start of the recording
+
+type Segment struct {
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	Text     string `json:"text"`
+	Language string `json:"language,omitempty"`
+	Words    []Word `json:"words,omitempty"`
+	// 1-based, 0 when the model doesn't detect speakers
+	Speaker int `json:"speaker,omitempty"`
+	// Next segment is said by another speaker
+	SpeakerTurn bool `json:"speakerTurn,omitempty"`
+	// Why the segment looks hallucinated, see Filter
+	Flags []string `json:"flags,omitempty"`
+}
+
+// Part of the recording transcribed with a single language
+type LanguageChunk struct {
+	Start       int64   `json:"start"`
+	End         int64   `json:"end"`
+	Language    string  `json:"language"`
+	Probability float32 `json:"probability"`
+}
+
+// Where a transcript revision came from
+const (
+	SourceTranscription = "transcription"
+	SourceManual        = "manual"
+	SourceRestore       = "restore"
+)
+
+type Transcript struct {
+	// Verbatim text as the engine produced it
+	Text string `json:"text"`
+	// Text after CleanText, empty when every stage is off
+	Clean string `json:"clean,omitempty"`
+	Model string `json:"model,omitempty"`
+	// Engine that produced the text, "local" or "remote"
+	Engine string `json:"engine,omitempty"`
+	// Name of the profile the engine settings came from, empty without one
+	Profile string `json:"profile,omitempty"`
+	// One of Source* constants, empty for transcripts saved before it existed
+	Source string `json:"source,omitempty"`
+	// Language used for transcription, detected one if user picked "auto"
+	Language            string          `json:"language,omitempty"`
+	LanguageProbability float32         `json:"languageProbability,omitempty"`
+	Languages           []LanguageChunk `json:"languages,omitempty"`
+	Segments            []Segment       `json:"segments,omitempty"`
+	// Whisper output before hallucinated segments were removed
+	Original *Transcript `json:"original,omitempty"`
+}
+
+// Text shown to the user, clean one when there is one
+func (t *Transcript) DisplayText() string {
+	if t.Clean != "" {
+		return t.Clean
+	}
+
+	return t.Text
+}
+
+// Segments have timings, but text edited by hand no longer matches them
+func (t *Transcript) SegmentsMatchText() bool {
+	if len(t.Segments) == 0 {
+		return false
+	}
+
+	texts := []string{}
+	for _, segment := range t.Segments {
+		texts = append(texts, segment.Text)
+	}
+
+	return slices.Equal(strings.Fields(strings.Join(texts, " ")), strings.Fields(t.Text))
+}
+
+// Moves every timestamp by ms, used when transcript of a part is placed into
+// the whole recording
+func (t *Transcript) Shift(ms int64) {
+	for i := range t.Segments {
+		segment := &t.Segments[i]
+		segment.Start += ms
+		segment.End += ms
+
+		for j := range segment.Words {
+			segment.Words[j].Start += ms
+			segment.Words[j].End += ms
+		}
+	}
+
+	for i := range t.Languages {
+		t.Languages[i].Start += ms
+		t.Languages[i].End += ms
+	}
+}
@@ -0,0 +1,225 @@
+package transcript
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Text token produced by whisper, usually a part of a word
+type Token struct {
+	Text        string
+	Start       int64
+	End         int64
+	Probability float32
+}
+
+type Word struct {
+	Text  string `json:"text"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	// Probability of the least certain token of the word
+	Probability float32 `json:"probability"`
+}
+
+// Whisper starts every new word with a space, tokens without it continue the
+// previous word. Punctuation sticks to the word before it
+func GroupWords(tokens []Token) []Word {
+	words := []Word{}
+
+	for _, token := range tokens {
+		text := strings.TrimSpace(token.Text)
+		if text == "" {
+			continue
+		}
+
+		startsWord := strings.HasPrefix(token.Text, " ") && !isPunctuation(text)
+		if len(words) == 0 || startsWord {
+			words = append(words, Word{
+				Text:        text,
+				Start:       token.Start,
+				End:         token.End,
+				Probability: token.Probability,
+			})
+			continue
+		}
+
+		last := &words[len(words)-1]
+		last.Text += text
+		last.End = max(last.End, token.End)
+		last.Probability = min(last.Probability, token.Probability)
+	}
+
+	return words
+}
+
+// Token timestamps sometimes overlap or spill out of the segment, keep words
+// inside it and in order, so seeking through them never jumps back
+func (s *Segment) FixWordTimes() {
+	prevEnd := s.Start
+
+	for i := range s.Words {
+		word := &s.Words[i]
+		word.Start = min(max(word.Start, prevEnd), s.End)
+		word.End = min(max(word.End, word.Start), s.End)
+		prevEnd = word.End
+	}
+}
+
+func isPunctuation(text string) bool {
+	for _, r := range text {
+		if !unicode.IsPunct(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Range of consecutive words whisper wasn't sure about. Offsets point into
+// DisplayText and are in UTF-16 code units, same as JS string indexes
+type Span struct {
+	Text        string  `json:"text"`
+	Start       int64   `json:"start"`
+	End         int64   `json:"end"`
+	TextStart   int     `json:"textStart"`
+	TextEnd     int     `json:"textEnd"`
+	Probability float32 `json:"probability"`
+}
+
+type wordPosition struct {
+	word  Word
+	start int
+	end   int
+}
+
+// Furthest a word is looked for in clean text after the previous one, in
+// bytes. It covers removed fillers and paragraph breaks, a word found
+// further is another occurrence of it
+const maxCleanGap = 64
+
+// Scripts written without spaces between words
+var unspacedScripts = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai,
+	unicode.Lao, unicode.Khmer, unicode.Myanmar, unicode.Tibetan,
+}
+
+// Whether the runes next to each other are parts of a single word
+func sameWord(a, b rune) bool {
+	isWord := func(r rune) bool {
+		return (unicode.IsLetter(r) || unicode.IsNumber(r)) && !unicode.In(r, unspacedScripts...)
+	}
+
+	return isWord(a) && isWord(b)
+}
+
+// Index of the whole word in s ignoring case, so "um" isn't found in
+// "umbrella". -1 when it doesn't start within maxGap bytes
+func indexWord(s, word string, maxGap int) int {
+	if word == "" {
+		return -1
+	}
+
+	first, _ := utf8.DecodeRuneInString(word)
+	last, _ := utf8.DecodeLastRuneInString(word)
+
+	for i := range s {
+		end := i + len(word)
+		if i > maxGap || end > len(s) {
+			break
+		}
+
+		if !strings.EqualFold(s[i:end], word) {
+			continue
+		}
+
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		if (i > 0 && sameWord(before, first)) || (end < len(s) && sameWord(last, after)) {
+			continue
+		}
+
+		return i
+	}
+
+	return -1
+}
+
+// Finds where every word is in DisplayText, the text shown in the note.
+// Clean text has fillers removed and casing fixed, so words are matched
+// ignoring case and only close to the previous one. Words missing from the
+// text, e.g. after a manual edit, are skipped
+func (t *Transcript) wordPositions() []wordPosition {
+	positions := []wordPosition{}
+	text, maxGap := t.Text, len(t.Text)
+	if t.Clean != "" {
+		text, maxGap = t.Clean, maxCleanGap
+	}
+
+	// Byte and UTF-16 offsets of the same place, so the text is walked once
+	cursor, cursor16 := 0, 0
+
+	for _, segment := range t.Segments {
+		for _, word := range segment.Words {
+			i := indexWord(text[cursor:], word.Text, maxGap)
+			if i < 0 {
+				continue
+			}
+
+			start16 := cursor16 + utf16Len(text[cursor:cursor+i])
+			cursor += i + len(word.Text)
+			cursor16 = start16 + utf16Len(word.Text)
+
+			positions = append(positions, wordPosition{
+				word:  word,
+				start: start16,
+				end:   cursor16,
+			})
+		}
+	}
+
+	return positions
+}
+
+func utf16Len(s string) int {
+	length := 0
+	for _, r := range s {
+		length += utf16.RuneLen(r)
+	}
+
+	return length
+}
+
+// Merges neighbouring words below the threshold into spans
+func (t *Transcript) LowConfidenceSpans(threshold float32) []Span {
+	spans := []Span{}
+	var current *Span
+
+	for _, pos := range t.wordPositions() {
+		if pos.word.Probability >= threshold {
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			spans = append(spans, Span{
+				Text:        pos.word.Text,
+				Start:       pos.word.Start,
+				End:         pos.word.End,
+				TextStart:   pos.start,
+				TextEnd:     pos.end,
+				Probability: pos.word.Probability,
+			})
+			current = &spans[len(spans)-1]
+			continue
+		}
+
+		current.Text += " " + pos.word.Text
+		current.End = pos.word.End
+		current.TextEnd = pos.end
+		current.Probability = min(current.Probability, pos.word.Probability)
+	}
+
+	return spans
+}
@@ -0,0 +1,120 @@
+package transcript
+
+import "testing"
+
+func wordsOf(texts ...string) []Word {
+	words := []Word{}
+	for i, text := range texts {
+		words = append(words, Word{Text: text, Start: int64(i * 100), End: int64(i*100 + 90), Probability: 1})
+	}
+
+	return words
+}
+
+func TestWordAtOffset(t *testing.T) {
+	tests := []struct {
+		name   string
+		result Transcript
+		offset int
+		// Text under the returned offsets, empty for no word
+		want string
+	}{
+		{
+			name:   "verbatim text",
+			result: Transcript{Text: "so um we ship it", Segments: []Segment{{Words: wordsOf("so", "um", "we", "ship", "it")}}},
+			offset: 9,
+			want:   "ship",
+		},
+		{
+			name:   "offsets in UTF-16",
+			result: Transcript{Text: "🎉 party time", Segments: []Segment{{Words: wordsOf("🎉", "party", "time")}}},
+			offset: 9,
+			want:   "time",
+		},
+		{
+			name: "clean text without fillers and with fixed casing",
+			result: Transcript{
+				Text:     "so um we ship it",
+				Clean:    "So we ship it.",
+				Segments: []Segment{{Words: wordsOf("so", "um", "we", "ship", "it")}},
+			},
+			offset: 6,
+			want:   "ship",
+		},
+		{
+			name: "removed word isn't matched far ahead",
+			result: Transcript{
+				Text:     "um let's talk about the umbrella budget",
+				Clean:    "Let's talk about the umbrella budget.",
+				Segments: []Segment{{Words: wordsOf("um", "let's", "talk", "about", "the", "umbrella", "budget")}},
+			},
+			offset: 0,
+			want:   "Let's",
+		},
+		{
+			name:   "words without spaces",
+			result: Transcript{Text: "今日は晴れです", Segments: []Segment{{Words: wordsOf("今日", "は", "晴れ", "です")}}},
+			offset: 3,
+			want:   "晴れ",
+		},
+		{
+			name:   "past the last word",
+			result: Transcript{Text: "hello", Segments: []Segment{{Words: wordsOf("hello")}}},
+			offset: 5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match := test.result.WordAtOffset(test.offset)
+			if match == nil {
+				if test.want != "" {
+					t.Fatalf("Expected %q, got no word", test.want)
+				}
+				return
+			}
+
+			text := []rune(test.result.DisplayText())
+			got := string(utf16Slice(text, match.TextStart, match.TextEnd))
+			if got != test.want {
+				t.Errorf("Expected %q, got %q at %d-%d", test.want, got, match.TextStart, match.TextEnd)
+			}
+		})
+	}
+}
+
+// Runes between UTF-16 offsets
+func utf16Slice(text []rune, start, end int) []rune {
+	offset := 0
+	result := []rune{}
+	for _, r := range text {
+		if offset >= start && offset < end {
+			result = append(result, r)
+		}
+		offset += utf16Len(string(r))
+	}
+
+	return result
+}
+
+func TestLowConfidenceSpansInCleanText(t *testing.T) {
+	words := wordsOf("uh", "the", "quarterly", "numbers", "look", "fine")
+	words[2].Probability = 0.2
+	words[3].Probability = 0.3
+
+	result := Transcript{
+		Text:     "uh the quarterly numbers look fine",
+		Clean:    "The quarterly numbers look fine.",
+		Segments: []Segment{{Words: words}},
+	}
+
+	spans := result.LowConfidenceSpans(0.5)
+	if len(spans) != 1 {
+		t.Fatalf("Expected a single span, got %+v", spans)
+	}
+
+	got := string([]rune(result.Clean)[spans[0].TextStart:spans[0].TextEnd])
+	if got != "quarterly numbers" {
+		t.Errorf("Span points at %q", got)
+	}
+}
@@ -0,0 +1,224 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Rate recordings are captured, stored and transcribed at, the one whisper
+// models expect
+const SampleRate = 16000
+
+// 16-bit mono PCM WAV
+func Encode(data []float32, sampleRate int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("No audio data provided")
+	}
+
+	numSamples := len(data)
+
+	var buf bytes.Buffer
+
+	byteRate := sampleRate * 2
+	blockAlign := 2
+
+	// --- WAV Header (44 bytes) ---
+	// ChunkID "RIFF"
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+numSamples*2))
+	buf.WriteString("WAVE")
+
+	// Subchunk1ID "fmt "
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16)) // Subchunk1Size
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // Mono
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(byteRate))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(16)) // BitsPerSample
+
+	// Subchunk2ID "data"
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(numSamples*2))
+
+	// --- Samples ---
+	for _, v := range data {
+		// Clamp and convert to int16
+		if v > 1.0 {
+			v = 1.0
+		} else if v < -1.0 {
+			v = -1.0
+		}
+		sample := int16(math.Round(float64(v) * 32767))
+		binary.Write(&buf, binary.LittleEndian, sample)
+	}
+
+	return buf.Bytes(), nil
+}
+
+const (
+	formatPcm        = 1
+	formatFloat      = 3
+	formatExtensible = 0xFFFE
+)
+
+type format struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// Reads PCM or float WAV into mono samples in [-1, 1], channels are averaged
+func Decode(data []byte) ([]float32, int, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("Not a WAV file")
+	}
+
+	var fmtChunk *format
+	pos := 12
+
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8 : min(pos+8+size, len(data))]
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, 0, fmt.Errorf("Invalid WAV format chunk")
+			}
+
+			fmtChunk = &format{}
+			binary.Read(bytes.NewReader(body[:16]), binary.LittleEndian, fmtChunk)
+
+			// Extensible format keeps the real one in the first bytes of the sub format GUID
+			if fmtChunk.AudioFormat == formatExtensible && len(body) >= 26 {
+				fmtChunk.AudioFormat = binary.LittleEndian.Uint16(body[24:26])
+			}
+		case "data":
+			if fmtChunk == nil {
+				return nil, 0, fmt.Errorf("WAV data comes before its format")
+			}
+
+			samples, err := decodeSamples(body, fmtChunk)
+			return samples, int(fmtChunk.SampleRate), err
+		}
+
+		// Chunks are padded to even size
+		pos += 8 + size + size%2
+	}
+
+	return nil, 0, fmt.Errorf("WAV file has no audio data")
+}
+
+func decodeSamples(body []byte, f *format) ([]float32, error) {
+	width := int(f.BitsPerSample) / 8
+	channels := int(f.Channels)
+	if width == 0 || channels == 0 {
+		return nil, fmt.Errorf("Invalid WAV format")
+	}
+
+	var sample func(b []byte) float32
+	switch {
+	case f.AudioFormat == formatPcm && width == 1:
+		sample = func(b []byte) float32 { return (float32(b[0]) - 128) / 128 }
+	case f.AudioFormat == formatPcm && width == 2:
+		sample = func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / 32768 }
+	case f.AudioFormat == formatPcm && width == 3:
+		sample = func(b []byte) float32 {
+			return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / 8388608
+		}
+	case f.AudioFormat == formatPcm && width == 4:
+		sample = func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648 }
+	case f.AudioFormat == formatFloat && width == 4:
+		sample = func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+	default:
+		return nil, fmt.Errorf("Unsupported WAV format %d with %d bits", f.AudioFormat, f.BitsPerSample)
+	}
+
+	frame := width * channels
+	samples := make([]float32, len(body)/frame)
+
+	for i := range samples {
+		sum := float32(0)
+		for c := range channels {
+			offset := i*frame + c*width
+			sum += sample(body[offset : offset+width])
+		}
+		samples[i] = sum / float32(channels)
+	}
+
+	return samples, nil
+}
+
+// Linear resampling, good enough for speech recognition
+func Resample(data []float32, from, to int) []float32 {
+	if from == to || from <= 0 || to <= 0 || len(data) == 0 {
+		return data
+	}
+
+	out := make([]float32, int(int64(len(data))*int64(to)/int64(from)))
+	step := float64(from) / float64(to)
+
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		if j+1 >= len(data) {
+			out[i] = data[len(data)-1]
+			continue
+		}
+
+		frac := float32(pos - float64(j))
+		out[i] = data[j]*(1-frac) + data[j+1]*frac
+	}
+
+	return out
+}
+
+// Length of the audio, read from the headers without decoding samples
+func Duration(r io.ReadSeeker) (time.Duration, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("Not a WAV file")
+	}
+
+	var byteRate uint32
+	chunk := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return 0, fmt.Errorf("WAV file has no audio data")
+		}
+
+		id := string(chunk[:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+
+		switch id {
+		case "fmt ":
+			var f format
+			if err := binary.Read(r, binary.LittleEndian, &f); err != nil {
+				return 0, fmt.Errorf("Invalid WAV format chunk")
+			}
+			byteRate = f.ByteRate
+			size -= 16
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("WAV data comes before its format")
+			}
+
+			return time.Duration(float64(size) / float64(byteRate) * float64(time.Second)), nil
+		}
+
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+}
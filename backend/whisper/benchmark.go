@@ -0,0 +1,293 @@
+package whisper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	whisperCpp "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"github.com/henmalib/whisper-notes/backend/sysinfo"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	benchmarkFile    = "benchmark.json"
+	benchmarkSeconds = 10
+	// Part of RAM a model is allowed to take, the rest is left for the OS and the app
+	maxRamShare = 0.6
+	// Rough MiB of model a single core processes in real time, used when
+	// there are no benchmarks to scale from
+	mibPerCoreRealtime = 400
+)
+
+type BenchmarkResult struct {
+	Model          string  `json:"model"`
+	AudioSeconds   float64 `json:"audioSeconds"`
+	ProcessSeconds float64 `json:"processSeconds"`
+	// Loading the model from disk, not part of ProcessSeconds
+	LoadSeconds float64 `json:"loadSeconds"`
+	// Processing time divided by audio length, below 1 is faster than real time
+	Rtf   float64   `json:"rtf"`
+	Cores int       `json:"cores"`
+	Date  time.Time `json:"date"`
+}
+
+type Recommendation struct {
+	Model     string       `json:"model"`
+	Rtf       float64      `json:"rtf"`
+	Estimated bool         `json:"estimated"`
+	Reason    string       `json:"reason"`
+	System    sysinfo.Info `json:"system"`
+}
+
+func (w *Whisper) GetSystemInfo() sysinfo.Info {
+	return sysinfo.Detect()
+}
+
+// Speech-like signal: a few harmonics of a wobbling pitch, modulated at
+// syllable rate with some noise on top. Seeded, so every run gets the same clip
+func syntheticClip(seconds int) []float32 {
+	rnd := rand.New(rand.NewSource(42))
+	samples := make([]float32, seconds*whisperCpp.SampleRate)
+	phase := 0.0
+
+	for i := range samples {
+		t := float64(i) / whisperCpp.SampleRate
+		pitch := 150 + 40*math.Sin(2*math.Pi*0.7*t)
+		phase += 2 * math.Pi * pitch / whisperCpp.SampleRate
+
+		voice := 0.0
+		for harmonic := 1.0; harmonic <= 5; harmonic++ {
+			voice += math.Sin(phase*harmonic) / harmonic
+		}
+
+		envelope := math.Max(0, math.Sin(2*math.Pi*4*t))
+		samples[i] = float32(0.3*voice*envelope + 0.01*rnd.NormFloat64())
+	}
+
+	return samples
+}
+
+func (w *Whisper) BenchmarkModel(modelname string) (BenchmarkResult, error) {
+	clip := syntheticClip(benchmarkSeconds)
+
+	// A short clip would mostly measure reading the model from disk
+	loadStart := time.Now()
+	model, err := w.loadModel(modelname)
+	if err != nil {
+		return BenchmarkResult{}, fmt.Errorf("Couldn't benchmark %s: %w", modelname, err)
+	}
+	defer model.Close()
+	loaded := time.Since(loadStart)
+
+	start := time.Now()
+	// Every model knows English, and a fixed language keeps detection out of the timing
+	if _, err := model.process(clip, "en", nil); err != nil {
+		return BenchmarkResult{}, fmt.Errorf("Couldn't benchmark %s: %w", modelname, err)
+	}
+	elapsed := time.Since(start)
+
+	result := BenchmarkResult{
+		Model:          modelname,
+		AudioSeconds:   benchmarkSeconds,
+		ProcessSeconds: elapsed.Seconds(),
+		LoadSeconds:    loaded.Seconds(),
+		Rtf:            elapsed.Seconds() / benchmarkSeconds,
+		Cores:          sysinfo.Detect().Cores,
+		Date:           time.Now(),
+	}
+
+	if err := w.saveBenchmark(result); err != nil {
+		return result, fmt.Errorf("Couldn't save benchmark result: %w", err)
+	}
+
+	return result, nil
+}
+
+// Benchmarks every installed model, emitting each result as soon as it's ready
+func (w *Whisper) RunBenchmark() ([]BenchmarkResult, error) {
+	results := []BenchmarkResult{}
+
+	models, err := w.ListInstalledModels()
+	if err != nil {
+		return results, err
+	}
+
+	for _, model := range models {
+		if model.Status == ModelStatusInvalid {
+			continue
+		}
+
+		result, err := w.BenchmarkModel(model.Name)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		runtime.EventsEmit(w.ctx, "whisper:benchmark", result)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (w *Whisper) GetBenchmarks() ([]BenchmarkResult, error) {
+	results, err := w.readBenchmarks()
+	list := make([]BenchmarkResult, 0, len(results))
+
+	for _, result := range results {
+		list = append(list, result)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Rtf < list[j].Rtf
+	})
+
+	return list, err
+}
+
+func (w *Whisper) readBenchmarks() (map[string]BenchmarkResult, error) {
+	results := map[string]BenchmarkResult{}
+
+	bytes, err := os.ReadFile(filepath.Join(w.modelsDir(), benchmarkFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return results, nil
+		}
+
+		return results, err
+	}
+
+	if err := json.Unmarshal(bytes, &results); err != nil {
+		return map[string]BenchmarkResult{}, fmt.Errorf("Invalid benchmark file: %w", err)
+	}
+
+	return results, nil
+}
+
+func (w *Whisper) saveBenchmark(result BenchmarkResult) error {
+	results, err := w.readBenchmarks()
+	if err != nil {
+		fmt.Println("Resetting benchmark results:", err)
+	}
+
+	results[result.Model] = result
+
+	bytes, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(w.modelsDir(), benchmarkFile), bytes, 0600)
+}
+
+// Picks the biggest model that fits into memory and processes audio at least
+// as fast as maxRtf. Models without benchmark results get an estimate scaled
+// from the closest benchmarked model by size
+func (w *Whisper) RecommendModel(language string, maxRtf float64) (Recommendation, error) {
+	if maxRtf <= 0 {
+		maxRtf = 1
+	}
+
+	info := sysinfo.Detect()
+
+	entries, err := w.loadRegistry()
+	if err != nil {
+		return Recommendation{System: info}, err
+	}
+
+	benchmarks, err := w.readBenchmarks()
+	if err != nil {
+		fmt.Println("Error while reading benchmarks:", err)
+	}
+
+	sizes := map[string]int64{}
+	for _, entry := range entries {
+		sizes[entry.Name] = entry.Size
+	}
+
+	estimate := func(entry ModelEntry) (float64, bool) {
+		if result, ok := benchmarks[entry.Name]; ok {
+			return result.Rtf, false
+		}
+
+		var closest *BenchmarkResult
+		closestDiff := int64(math.MaxInt64)
+		for _, result := range benchmarks {
+			size, ok := sizes[result.Model]
+			if !ok || size == 0 {
+				continue
+			}
+
+			diff := size - entry.Size
+			if diff < 0 {
+				diff = -diff
+			}
+
+			if diff < closestDiff {
+				closestDiff = diff
+				closest = &result
+			}
+		}
+
+		if closest != nil {
+			return closest.Rtf * float64(entry.Size) / float64(sizes[closest.Model]), true
+		}
+
+		return float64(entry.Size) / (1024 * 1024) / float64(info.Cores*mibPerCoreRealtime), true
+	}
+
+	var best, fastest *Recommendation
+	var bestSize int64
+
+	for _, entry := range entries {
+		if language == "" || language == "auto" {
+			if !entry.Multilingual {
+				continue
+			}
+		} else if len(entry.Languages) > 0 && !slices.Contains(entry.Languages, language) {
+			continue
+		}
+
+		if info.Memory > 0 && float64(entry.RamMb)*1024*1024 > float64(info.Memory)*maxRamShare {
+			continue
+		}
+
+		rtf, estimated := estimate(entry)
+		candidate := Recommendation{
+			Model:     entry.Name,
+			Rtf:       rtf,
+			Estimated: estimated,
+			System:    info,
+		}
+
+		if fastest == nil || rtf < fastest.Rtf {
+			fastest = &candidate
+		}
+
+		// Registry lists newer models later, so they win ties
+		if rtf <= maxRtf && entry.Size >= bestSize {
+			best = &candidate
+			bestSize = entry.Size
+		}
+	}
+
+	if best != nil {
+		best.Reason = fmt.Sprintf("Biggest model that runs at %.2fx real time or faster", maxRtf)
+		return *best, nil
+	}
+
+	if fastest != nil {
+		fastest.Reason = "No model reaches the requested speed, picked the fastest one"
+		return *fastest, nil
+	}
+
+	return Recommendation{System: info}, fmt.Errorf("No model fits into %d MiB of memory", info.Memory/1024/1024)
+}
@@ -0,0 +1,100 @@
+package whisper
+
+/*
+#include <whisper.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	whisperLow "github.com/ggerganov/whisper.cpp/bindings/go"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+// tinydiarize only marks turns, it can't tell who is speaking. Turns are
+// assigned round-robin, which matches a two person conversation
+const diarizeSpeakers = 2
+
+// Neither bindings expose tdrz_enable nor speaker turns, cgo reaches them
+// through the raw whisper context
+
+func enableTinydiarize(params *whisperLow.Params) {
+	(*C.struct_whisper_full_params)(unsafe.Pointer(params)).tdrz_enable = C.bool(true)
+}
+
+func speakerTurnNext(ctx *whisperLow.Context, segment int) bool {
+	return bool(C.whisper_full_get_segment_speaker_turn_next((*C.struct_whisper_context)(unsafe.Pointer(ctx)), C.int(segment)))
+}
+
+func (m *loadedModel) transcribeDiarized(data []float32, processCallback func(int)) (*transcript.Transcript, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("No audio data provided")
+	}
+
+	ctx := m.ctx
+	params := m.defaultParams()
+	// tinydiarize models are English only
+	if err := params.SetLanguage(ctx.Whisper_lang_id("en")); err != nil {
+		return nil, err
+	}
+	enableTinydiarize(&params)
+
+	if err := ctx.Whisper_full(params, data, nil, nil, func(progress int) {
+		if processCallback != nil {
+			processCallback(progress)
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("Unable to process audio file: %w", err)
+	}
+
+	result := &transcript.Transcript{
+		Model:    m.name,
+		Language: "en",
+	}
+	texts := []string{}
+	turn := 0
+	eot := ctx.Whisper_token_eot()
+
+	for i := range ctx.Whisper_full_n_segments() {
+		tokens := []transcript.Token{}
+		for j := range ctx.Whisper_full_n_tokens(i) {
+			// Everything starting from EOT are special tokens, including speaker turns
+			if ctx.Whisper_full_get_token_id(i, j) >= eot {
+				continue
+			}
+
+			data := ctx.Whisper_full_get_token_data(i, j)
+			tokens = append(tokens, transcript.Token{
+				Text:        ctx.Whisper_full_get_token_text(i, j),
+				Start:       data.T0() * 10,
+				End:         data.T1() * 10,
+				Probability: ctx.Whisper_full_get_token_p(i, j),
+			})
+		}
+
+		segment := transcript.Segment{
+			Start:       ctx.Whisper_full_get_segment_t0(i) * 10,
+			End:         ctx.Whisper_full_get_segment_t1(i) * 10,
+			Text:        strings.TrimSpace(ctx.Whisper_full_get_segment_text(i)),
+			Words:       transcript.GroupWords(tokens),
+			Speaker:     turn%diarizeSpeakers + 1,
+			SpeakerTurn: speakerTurnNext(ctx, i),
+		}
+
+		segment.FixWordTimes()
+
+		if segment.SpeakerTurn {
+			turn++
+		}
+
+		texts = append(texts, segment.Text)
+		result.Segments = append(result.Segments, segment)
+	}
+
+	result.Text = strings.Join(texts, " ")
+
+	return result, nil
+}
@@ -0,0 +1,634 @@
+package whisper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/henmalib/whisper-notes/backend/config"
+	"github.com/henmalib/whisper-notes/backend/network"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	partExt            = ".part"
+	maxDownloadRetries = 5
+	retryBaseDelay     = 2 * time.Second
+	retryMaxDelay      = time.Minute
+	reportInterval     = time.Second
+	// Finished downloads can be looked up for a while, then they're forgotten
+	finishedRetention = 10 * time.Minute
+)
+
+var (
+	errDownloadCancelled = errors.New("download was cancelled")
+	errDownloadPaused    = errors.New("download was paused")
+)
+
+type DownloadState string
+
+const (
+	DownloadQueued    DownloadState = "queued"
+	DownloadRunning   DownloadState = "running"
+	DownloadPaused    DownloadState = "paused"
+	DownloadRetrying  DownloadState = "retrying"
+	DownloadCompleted DownloadState = "completed"
+	DownloadFailed    DownloadState = "failed"
+	DownloadCancelled DownloadState = "cancelled"
+)
+
+type DownloadProgress struct {
+	Id    string        `json:"id"`
+	Model string        `json:"model"`
+	State DownloadState `json:"state"`
+	Bytes int64         `json:"bytes"`
+	// -1 when server didn't send Content-Length
+	Total int64 `json:"total"`
+	// Bytes per second
+	Speed float64 `json:"speed"`
+	// Seconds left, -1 when unknown
+	Eta     float64 `json:"eta"`
+	Attempt int     `json:"attempt"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type httpStatusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.url, e.status)
+}
+
+func isRetryable(err error) bool {
+	if errors.Is(err, network.ErrInvalidConfig) {
+		return false
+	}
+
+	// Errors of local files, like a full disk, won't go away by themselves
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) {
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code >= 500 || statusErr.code == http.StatusTooManyRequests
+	}
+
+	return true
+}
+
+type downloadTask struct {
+	url string
+	out string
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	resume chan struct{}
+	done   chan struct{}
+
+	mu        sync.Mutex
+	progress  DownloadProgress
+	runCancel context.CancelCauseFunc
+	err       error
+}
+
+func (t *downloadTask) snapshot() DownloadProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.progress
+}
+
+func (t *downloadTask) update(fn func(p *DownloadProgress)) DownloadProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(&t.progress)
+
+	return t.progress
+}
+
+// Lets a paused download continue, false when it isn't paused
+func (t *downloadTask) resumePaused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.progress.State != DownloadPaused {
+		return false
+	}
+
+	t.progress.State = DownloadQueued
+	select {
+	case t.resume <- struct{}{}:
+	default:
+	}
+
+	return true
+}
+
+type downloadManager struct {
+	ctx    context.Context
+	config config.ConfigLoader
+	slots  chan struct{}
+
+	mu     sync.Mutex
+	tasks  map[string]*downloadTask
+	active map[string]*downloadTask
+}
+
+func newDownloadManager(ctx context.Context, config config.ConfigLoader) *downloadManager {
+	maxConcurrent := config.GetConfig().MaxConcurrentDownloads
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	return &downloadManager{
+		ctx:    ctx,
+		config: config,
+		slots:  make(chan struct{}, maxConcurrent),
+		tasks:  map[string]*downloadTask{},
+		active: map[string]*downloadTask{},
+	}
+}
+
+// Starts downloading a model in background, if the model is already being
+// downloaded, returns the existing task instead
+func (m *downloadManager) start(model, url, out string) *downloadTask {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if task, ok := m.active[model]; ok {
+		return task
+	}
+
+	ctx, cancel := context.WithCancelCause(m.ctx)
+	task := &downloadTask{
+		url:    url,
+		out:    out,
+		ctx:    ctx,
+		cancel: cancel,
+		resume: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		progress: DownloadProgress{
+			Id:    uuid.New().String(),
+			Model: model,
+			State: DownloadQueued,
+			Total: -1,
+			Eta:   -1,
+		},
+	}
+
+	m.tasks[task.progress.Id] = task
+	m.active[model] = task
+
+	go m.run(task)
+
+	return task
+}
+
+func (m *downloadManager) get(id string) (*downloadTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	task, ok := m.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("Download %s wasn't found", id)
+	}
+
+	return task, nil
+}
+
+func (m *downloadManager) list() []DownloadProgress {
+	m.mu.Lock()
+	tasks := make([]*downloadTask, 0, len(m.tasks))
+	for _, task := range m.tasks {
+		tasks = append(tasks, task)
+	}
+	m.mu.Unlock()
+
+	list := make([]DownloadProgress, 0, len(tasks))
+	for _, task := range tasks {
+		list = append(list, task.snapshot())
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Model < list[j].Model
+	})
+
+	return list
+}
+
+func (m *downloadManager) run(task *downloadTask) {
+	defer close(task.done)
+	defer task.cancel(nil)
+
+	attempt := 0
+
+	for {
+		runCtx, runCancel := context.WithCancelCause(task.ctx)
+		task.mu.Lock()
+		task.runCancel = runCancel
+		// Paused before the attempt had a context to cancel
+		paused := task.progress.State == DownloadPaused
+		task.mu.Unlock()
+
+		var err error
+		if paused {
+			runCancel(errDownloadPaused)
+			err = errDownloadPaused
+		} else {
+			err = m.attempt(runCtx, task)
+		}
+		cause := context.Cause(runCtx)
+		runCancel(nil)
+
+		if err == nil {
+			m.finish(task, DownloadCompleted, nil)
+			return
+		}
+
+		switch {
+		case errors.Is(cause, errDownloadPaused):
+			// State is set by PauseDownload already, resuming may have changed it
+			m.report(task.update(func(p *DownloadProgress) {
+				p.Speed = 0
+				p.Eta = -1
+			}))
+
+			select {
+			case <-task.resume:
+				attempt = 0
+				continue
+			case <-task.ctx.Done():
+				m.finish(task, DownloadCancelled, context.Cause(task.ctx))
+				return
+			}
+		case cause != nil:
+			m.finish(task, DownloadCancelled, cause)
+			return
+		}
+
+		attempt++
+		if !isRetryable(err) || attempt > maxDownloadRetries {
+			m.finish(task, DownloadFailed, err)
+			return
+		}
+
+		fmt.Printf("Download of %s failed, retrying (%d/%d): %s\n", task.progress.Model, attempt, maxDownloadRetries, err)
+		m.report(task.update(func(p *DownloadProgress) {
+			p.State = DownloadRetrying
+			p.Attempt = attempt
+			p.Error = err.Error()
+			p.Speed = 0
+			p.Eta = -1
+		}))
+
+		delay := min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+
+		select {
+		case <-time.After(delay):
+		case <-task.ctx.Done():
+			m.finish(task, DownloadCancelled, context.Cause(task.ctx))
+			return
+		}
+	}
+}
+
+func (m *downloadManager) finish(task *downloadTask, state DownloadState, err error) {
+	if state == DownloadCancelled {
+		if rmErr := os.Remove(task.out + partExt); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			fmt.Println("Couldn't remove partial download:", rmErr)
+		}
+	}
+
+	progress := task.update(func(p *DownloadProgress) {
+		p.State = state
+		p.Speed = 0
+		p.Eta = -1
+		p.Error = ""
+		if err != nil {
+			p.Error = err.Error()
+		}
+	})
+
+	task.mu.Lock()
+	task.err = err
+	task.mu.Unlock()
+
+	m.mu.Lock()
+	if m.active[progress.Model] == task {
+		delete(m.active, progress.Model)
+	}
+	m.mu.Unlock()
+
+	m.report(progress)
+
+	time.AfterFunc(finishedRetention, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		delete(m.tasks, progress.Id)
+	})
+}
+
+// Size of the whole file from Content-Range of a 416 response, "bytes */1234"
+func rangeSize(contentRange string) (int64, bool) {
+	size, found := strings.CutPrefix(contentRange, "bytes */")
+	if !found {
+		return 0, false
+	}
+
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return total, true
+}
+
+func request(ctx context.Context, client *http.Client, url string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	return client.Do(req)
+}
+
+func (m *downloadManager) attempt(ctx context.Context, task *downloadTask) error {
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-m.slots }()
+
+	partPath := task.out + partExt
+
+	if err := os.MkdirAll(filepath.Dir(task.out), 0755); err != nil {
+		return fmt.Errorf("Error while creating models folder %s: %w", task.out, err)
+	}
+
+	var offset int64
+	if stat, err := os.Stat(partPath); err == nil {
+		offset = stat.Size()
+	}
+
+	cfg := m.config.GetConfig()
+
+	client, err := network.NewClient(cfg)
+	if err != nil {
+		return fmt.Errorf("Couldn't create http client: %w", err)
+	}
+
+	resp, err := request(ctx, client, task.url, offset)
+	if err != nil {
+		return err
+	}
+
+	// Nothing is left after the offset, the partial file is either complete
+	// or bigger than the remote one
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+
+		if total, ok := rangeSize(resp.Header.Get("Content-Range")); ok && total == offset {
+			m.report(task.update(func(p *DownloadProgress) {
+				p.Bytes = offset
+				p.Total = total
+			}))
+
+			return os.Rename(partPath, task.out)
+		}
+
+		if err := os.Remove(partPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Couldn't remove partial download: %w", err)
+		}
+
+		offset = 0
+		if resp, err = request(ctx, client, task.url, offset); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	default:
+		return &httpStatusError{task.url, resp.StatusCode, resp.Status}
+	}
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("Error while creating a file %s: %w", partPath, err)
+	}
+	defer file.Close()
+
+	m.report(task.update(func(p *DownloadProgress) {
+		p.State = DownloadRunning
+		p.Bytes = offset
+		p.Total = total
+		p.Error = ""
+	}))
+
+	body := network.LimitReader(ctx, resp.Body, cfg.BandwidthLimit)
+
+	if err := m.copy(ctx, task, file, body, offset, total); err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partPath, task.out)
+}
+
+func (m *downloadManager) copy(ctx context.Context, task *downloadTask, w io.Writer, r io.Reader, count, total int64) error {
+	data := make([]byte, bufSize)
+
+	lastReport := time.Now()
+	lastCount := count
+	speed := float64(0)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := r.Read(data)
+
+		if n > 0 {
+			if _, werr := w.Write(data[:n]); werr != nil {
+				return werr
+			}
+
+			count += int64(n)
+		}
+
+		if elapsed := time.Since(lastReport); elapsed >= reportInterval || err != nil {
+			current := float64(count-lastCount) / elapsed.Seconds()
+			if speed == 0 {
+				speed = current
+			} else {
+				// Smooth the speed so ETA doesn't jump around
+				speed = 0.3*current + 0.7*speed
+			}
+
+			eta := float64(-1)
+			if total > 0 && speed > 0 {
+				eta = float64(total-count) / speed
+			}
+
+			m.report(task.update(func(p *DownloadProgress) {
+				p.Bytes = count
+				p.Speed = speed
+				p.Eta = eta
+			}))
+
+			lastReport = time.Now()
+			lastCount = count
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if total > 0 && count != total {
+					return fmt.Errorf("Download ended early: got %d of %d bytes", count, total)
+				}
+
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+func (m *downloadManager) report(progress DownloadProgress) {
+	runtime.EventsEmit(m.ctx, "whisper:download:progress", progress)
+
+	if progress.Total > 0 {
+		percentage := int8(progress.Bytes * 100 / progress.Total)
+		runtime.EventsEmit(m.ctx, fmt.Sprintf("whisper:download:%s", progress.Model), percentage)
+	}
+}
+
+// Starts downloading the model in background and returns the download id
+func (w *Whisper) StartDownload(model string) (string, error) {
+	task, err := w.startDownload(model)
+	if err != nil {
+		return "", err
+	}
+
+	return task.snapshot().Id, nil
+}
+
+func (w *Whisper) startDownload(model string) (*downloadTask, error) {
+	if err := validateModelName(model); err != nil {
+		return nil, err
+	}
+
+	entry, err := w.findModel(model)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := entry.url()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't resolve model url: %w", err)
+	}
+
+	return w.downloads.start(model, url, w.getModelPath(model)), nil
+}
+
+func (w *Whisper) CancelDownload(id string) error {
+	task, err := w.downloads.get(id)
+	if err != nil {
+		return err
+	}
+
+	task.cancel(errDownloadCancelled)
+
+	return nil
+}
+
+func (w *Whisper) PauseDownload(id string) error {
+	task, err := w.downloads.get(id)
+	if err != nil {
+		return err
+	}
+
+	task.mu.Lock()
+	defer task.mu.Unlock()
+
+	if state := task.progress.State; state != DownloadRunning && state != DownloadQueued {
+		return fmt.Errorf("Download %s can't be paused, it's %s", id, state)
+	}
+
+	// Without a running attempt, the next one sees the state and waits
+	if task.runCancel != nil {
+		task.runCancel(errDownloadPaused)
+	}
+	task.progress.State = DownloadPaused
+
+	return nil
+}
+
+func (w *Whisper) ResumeDownload(id string) error {
+	task, err := w.downloads.get(id)
+	if err != nil {
+		return err
+	}
+
+	if !task.resumePaused() {
+		return fmt.Errorf("Download %s is not paused, it's %s", id, task.snapshot().State)
+	}
+
+	return nil
+}
+
+func (w *Whisper) GetDownload(id string) (DownloadProgress, error) {
+	task, err := w.downloads.get(id)
+	if err != nil {
+		return DownloadProgress{}, err
+	}
+
+	return task.snapshot(), nil
+}
+
+func (w *Whisper) ListDownloads() []DownloadProgress {
+	return w.downloads.list()
+}
@@ -0,0 +1,179 @@
+package whisper
+
+import (
+	"errors"
+	"fmt"
+	goruntime "runtime"
+	"sort"
+	"strings"
+
+	whisperLow "github.com/ggerganov/whisper.cpp/bindings/go"
+	whisperCpp "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+const (
+	// Whisper looks at a single 30 second window when detecting language
+	detectSeconds       = 30
+	defaultChunkSeconds = 30
+)
+
+type LanguageProbability struct {
+	Language    string  `json:"language"`
+	Probability float32 `json:"probability"`
+}
+
+func (m *loadedModel) detectLanguage(data []float32) ([]LanguageProbability, error) {
+	if len(data) == 0 {
+		return nil, errors.New("No audio data provided")
+	}
+
+	if !m.isMultilingual() {
+		return nil, whisperCpp.ErrModelNotMultilingual
+	}
+
+	threads := min(goruntime.NumCPU(), 8)
+	if err := m.ctx.Whisper_pcm_to_mel(data, threads); err != nil {
+		return nil, fmt.Errorf("Couldn't compute mel spectrogram: %w", err)
+	}
+
+	probs, err := m.ctx.Whisper_lang_auto_detect(0, threads)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]LanguageProbability, 0, len(probs))
+	for id, p := range probs {
+		result = append(result, LanguageProbability{
+			Language:    whisperLow.Whisper_lang_str(id),
+			Probability: p,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Probability > result[j].Probability
+	})
+
+	return result, nil
+}
+
+// Returns languages ranked by probability, looking only at the first `seconds` of audio
+func (w *Whisper) DetectLanguage(modelname string, data []float32, seconds int) ([]LanguageProbability, error) {
+	if seconds <= 0 {
+		seconds = detectSeconds
+	}
+
+	model, err := w.loadModel(modelname)
+	if err != nil {
+		return nil, err
+	}
+	defer model.Close()
+
+	return model.detectLanguage(data[:min(len(data), seconds*whisperCpp.SampleRate)])
+}
+
+// Detects the most probable language of every chunk
+func (w *Whisper) DetectLanguages(modelname string, data []float32, chunkSeconds int) ([]transcript.LanguageChunk, error) {
+	model, err := w.loadModel(modelname)
+	if err != nil {
+		return nil, err
+	}
+	defer model.Close()
+
+	return model.detectLanguages(data, chunkSeconds)
+}
+
+func (m *loadedModel) detectLanguages(data []float32, chunkSeconds int) ([]transcript.LanguageChunk, error) {
+	if chunkSeconds <= 0 {
+		chunkSeconds = defaultChunkSeconds
+	}
+
+	chunkSize := chunkSeconds * whisperCpp.SampleRate
+	chunks := []transcript.LanguageChunk{}
+
+	for start := 0; start < len(data); start += chunkSize {
+		end := min(start+chunkSize, len(data))
+
+		probs, err := m.detectLanguage(data[start:end])
+		if err != nil {
+			return chunks, err
+		}
+
+		chunks = append(chunks, transcript.LanguageChunk{
+			Start:       samplesToMs(start),
+			End:         samplesToMs(end),
+			Language:    probs[0].Language,
+			Probability: probs[0].Probability,
+		})
+	}
+
+	return chunks, nil
+}
+
+// Transcribes every chunk with its own detected language, so meetings that
+// switch between languages don't get translated into a single one
+func (w *Whisper) ProcessMixedLanguages(modelname string, data []float32, chunkSeconds int, processCallback func(int)) (*transcript.Transcript, error) {
+	model, err := w.loadModel(modelname)
+	if err != nil {
+		return nil, err
+	}
+	defer model.Close()
+
+	chunks, err := model.detectLanguages(data, chunkSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't detect languages: %w", err)
+	}
+
+	result := &transcript.Transcript{
+		Model:     modelname,
+		Languages: chunks,
+	}
+	texts := []string{}
+	durations := map[string]int64{}
+
+	for i, chunk := range chunks {
+		part := data[msToSamples(chunk.Start):min(msToSamples(chunk.End), len(data))]
+
+		chunkResult, err := model.transcribe(part, chunk.Language, func(progress int) {
+			if processCallback != nil {
+				processCallback((i*100 + progress) / len(chunks))
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		chunkResult.Shift(chunk.Start)
+		for _, segment := range chunkResult.Segments {
+			segment.Language = chunk.Language
+			result.Segments = append(result.Segments, segment)
+		}
+
+		if chunkResult.Text != "" {
+			texts = append(texts, chunkResult.Text)
+		}
+
+		durations[chunk.Language] += chunk.End - chunk.Start
+	}
+
+	// The language spoken the most represents the whole recording
+	for _, chunk := range chunks {
+		if durations[chunk.Language] > durations[result.Language] {
+			result.Language = chunk.Language
+			result.LanguageProbability = chunk.Probability
+		}
+	}
+
+	result.Text = strings.Join(texts, " ")
+	w.markModelUsed(modelname)
+
+	return result, nil
+}
+
+func samplesToMs(samples int) int64 {
+	return int64(samples) * 1000 / whisperCpp.SampleRate
+}
+
+func msToSamples(ms int64) int {
+	return int(ms * whisperCpp.SampleRate / 1000)
+}
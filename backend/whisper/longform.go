@@ -0,0 +1,407 @@
+package whisper
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	whisperCpp "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"github.com/henmalib/whisper-notes/backend/transcript"
+)
+
+const (
+	jobFile   = "job.json"
+	jobAudio  = "audio.pcm"
+	silenceMs = 20
+)
+
+type audioChunk struct {
+	// Samples, Start includes the overlap with the previous chunk
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Where the chunk would start without overlap
+	From int `json:"from"`
+}
+
+type LongJob struct {
+	Id        string    `json:"id"`
+	Model     string    `json:"model"`
+	Language  string    `json:"language"`
+	Samples   int       `json:"samples"`
+	Chunks    int       `json:"chunks"`
+	Done      int       `json:"done"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type longJobFile struct {
+	Id        string       `json:"id"`
+	Model     string       `json:"model"`
+	Language  string       `json:"language"`
+	Samples   int          `json:"samples"`
+	Chunks    []audioChunk `json:"chunks"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+// RMS of a short window, used to find the quietest place to cut at
+func windowEnergy(data []float32) float64 {
+	sum := 0.0
+	for _, v := range data {
+		sum += float64(v) * float64(v)
+	}
+
+	return math.Sqrt(sum / float64(max(len(data), 1)))
+}
+
+// Splits audio into chunks of about chunkSamples, cutting at the quietest
+// point of the last quarter of every chunk. Every chunk except the first
+// starts overlapSamples earlier, so words at the border aren't lost
+func splitAtSilence(data []float32, chunkSamples, overlapSamples int) []audioChunk {
+	chunks := []audioChunk{}
+	window := whisperCpp.SampleRate * silenceMs / 1000
+
+	if chunkSamples <= window {
+		chunkSamples = len(data)
+	}
+
+	search := chunkSamples / 4
+
+	for from := 0; from < len(data); {
+		target := from + chunkSamples
+		cut := len(data)
+
+		if target < len(data) {
+			cut = target
+			quietest := math.MaxFloat64
+
+			for pos := target - search; pos+window <= target; pos += window {
+				if energy := windowEnergy(data[pos : pos+window]); energy < quietest {
+					quietest = energy
+					cut = pos + window/2
+				}
+			}
+		}
+
+		chunks = append(chunks, audioChunk{
+			Start: max(0, from-overlapSamples),
+			End:   cut,
+			From:  from,
+		})
+		from = cut
+	}
+
+	return chunks
+}
+
+// binary.Write would copy the whole recording at once, hours of audio take
+// hundreds of megabytes, so write it in blocks
+func writeSamples(w io.Writer, data []float32) error {
+	const block = 64 * 1024
+
+	for start := 0; start < len(data); start += block {
+		if err := binary.Write(w, binary.LittleEndian, data[start:min(start+block, len(data))]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Whisper) checkpointsDir() string {
+	return os.ExpandEnv(w.config.GetConfig().CheckpointPath)
+}
+
+func longJobId(modelname, lang string, data []float32) string {
+	hash := sha1.New()
+	hash.Write([]byte(modelname + "|" + lang + "|"))
+	writeSamples(hash, data)
+
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+func (w *Whisper) jobPath(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", fmt.Errorf("Invalid job id %q", id)
+	}
+
+	return filepath.Join(w.checkpointsDir(), id), nil
+}
+
+func chunkFile(i int) string {
+	return fmt.Sprintf("chunk-%d.json", i)
+}
+
+func (w *Whisper) createLongJob(modelname string, data []float32, lang string) (*longJobFile, error) {
+	id := longJobId(modelname, lang, data)
+	jobPath, err := w.jobPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job, err := readLongJob(jobPath); err == nil {
+		return job, nil
+	}
+
+	cfg := w.config.GetConfig()
+	job := &longJobFile{
+		Id:        id,
+		Model:     modelname,
+		Language:  lang,
+		Samples:   len(data),
+		Chunks:    splitAtSilence(data, cfg.LongAudioChunkSeconds*whisperCpp.SampleRate, cfg.LongAudioOverlapSeconds*whisperCpp.SampleRate),
+		CreatedAt: time.Now(),
+	}
+
+	if err := os.MkdirAll(jobPath, 0755); err != nil {
+		return nil, fmt.Errorf("Couldn't create checkpoint directory: %w", err)
+	}
+
+	audioFile, err := os.Create(filepath.Join(jobPath, jobAudio))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't save audio checkpoint: %w", err)
+	}
+	defer audioFile.Close()
+
+	if err := writeSamples(audioFile, data); err != nil {
+		return nil, fmt.Errorf("Couldn't save audio checkpoint: %w", err)
+	}
+
+	// job.json goes last, so a job without it is never picked up half written
+	bytes, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(jobPath, jobFile), bytes, 0600); err != nil {
+		return nil, fmt.Errorf("Couldn't save job checkpoint: %w", err)
+	}
+
+	return job, nil
+}
+
+func readLongJob(jobPath string) (*longJobFile, error) {
+	bytes, err := os.ReadFile(filepath.Join(jobPath, jobFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var job longJobFile
+	if err := json.Unmarshal(bytes, &job); err != nil {
+		return nil, fmt.Errorf("Invalid job file: %w", err)
+	}
+
+	return &job, nil
+}
+
+func readChunkResult(jobPath string, i int) (*transcript.Transcript, error) {
+	bytes, err := os.ReadFile(filepath.Join(jobPath, chunkFile(i)))
+	if err != nil {
+		return nil, err
+	}
+
+	var result transcript.Transcript
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func writeChunkResult(jobPath string, i int, result *transcript.Transcript) error {
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(jobPath, chunkFile(i)+".tmp")
+	if err := os.WriteFile(tmpPath, bytes, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(jobPath, chunkFile(i)))
+}
+
+// Transcribes long audio chunk by chunk, saving every finished chunk so the
+// job can be resumed after a crash. The checkpoint is kept until DeleteLongJob
+func (w *Whisper) ProcessLong(modelname string, data []float32, lang string, processCallback func(int)) (*transcript.Transcript, string, error) {
+	if len(data) == 0 {
+		return nil, "", errors.New("No audio data provided")
+	}
+
+	job, err := w.createLongJob(modelname, data, lang)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result, err := w.runLongJob(job, data, processCallback)
+
+	return result, job.Id, err
+}
+
+func (w *Whisper) ResumeLongJob(id string, processCallback func(int)) (*transcript.Transcript, error) {
+	jobPath, err := w.jobPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := readLongJob(jobPath)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read job %s: %w", id, err)
+	}
+
+	data, err := w.LongJobAudio(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.runLongJob(job, data, processCallback)
+}
+
+func (w *Whisper) runLongJob(job *longJobFile, data []float32, processCallback func(int)) (*transcript.Transcript, error) {
+	jobPath, err := w.jobPath(job.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &transcript.Transcript{}
+	lang := job.Language
+
+	// Loaded once for every chunk, and only when some of them aren't checkpointed
+	var model *loadedModel
+	defer func() {
+		if model != nil {
+			model.Close()
+		}
+	}()
+
+	for i, chunk := range job.Chunks {
+		part, err := readChunkResult(jobPath, i)
+
+		if err != nil {
+			if model == nil {
+				if model, err = w.loadModel(job.Model); err != nil {
+					return nil, err
+				}
+			}
+
+			part, err = model.process(data[chunk.Start:chunk.End], lang, func(progress int) {
+				if processCallback != nil {
+					processCallback((i*100 + progress) / len(job.Chunks))
+				}
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Couldn't transcribe chunk %d of %d: %w", i+1, len(job.Chunks), err)
+			}
+
+			part.Shift(samplesToMs(chunk.Start))
+
+			if err := writeChunkResult(jobPath, i, part); err != nil {
+				fmt.Println("Couldn't checkpoint chunk:", err)
+			}
+		}
+
+		// Language detected in the first chunk is used for the rest, so
+		// detection runs once
+		if part.Language != "" {
+			lang = part.Language
+		}
+		result.Stitch(part, samplesToMs(chunk.From))
+	}
+
+	w.markModelUsed(job.Model)
+
+	return result, nil
+}
+
+func (w *Whisper) LongJobAudio(id string) ([]float32, error) {
+	jobPath, err := w.jobPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(jobPath, jobAudio))
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read job audio: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float32, stat.Size()/4)
+	if err := binary.Read(file, binary.LittleEndian, data); err != nil {
+		return nil, fmt.Errorf("Couldn't read job audio: %w", err)
+	}
+
+	return data, nil
+}
+
+// Unfinished jobs, left after a crash or a restart
+func (w *Whisper) ListLongJobs() ([]LongJob, error) {
+	jobs := []LongJob{}
+
+	dirs, err := os.ReadDir(w.checkpointsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return jobs, nil
+		}
+
+		return jobs, err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+
+		jobPath := filepath.Join(w.checkpointsDir(), dir.Name())
+		job, err := readLongJob(jobPath)
+		if err != nil {
+			fmt.Println("Skipping broken job", dir.Name(), err)
+			continue
+		}
+
+		done := 0
+		for i := range job.Chunks {
+			if _, err := os.Stat(filepath.Join(jobPath, chunkFile(i))); err == nil {
+				done++
+			}
+		}
+
+		jobs = append(jobs, LongJob{
+			Id:        job.Id,
+			Model:     job.Model,
+			Language:  job.Language,
+			Samples:   job.Samples,
+			Chunks:    len(job.Chunks),
+			Done:      done,
+			CreatedAt: job.CreatedAt,
+		})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	return jobs, nil
+}
+
+func (w *Whisper) DeleteLongJob(id string) error {
+	jobPath, err := w.jobPath(id)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(jobPath)
+}
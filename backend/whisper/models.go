@@ -0,0 +1,253 @@
+package whisper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const usageFile = "usage.json"
+
+// ggml model files start with the "ggml" magic stored as little-endian uint32
+var ggmlMagic = []byte("lmgg")
+
+type ModelStatus string
+
+const (
+	// File looks like a ggml model, checksum wasn't checked
+	ModelStatusOk      ModelStatus = "ok"
+	ModelStatusInvalid ModelStatus = "invalid"
+	// Registry has no checksum for the model
+	ModelStatusUnknown  ModelStatus = "unknown"
+	ModelStatusMismatch ModelStatus = "mismatch"
+	ModelStatusVerified ModelStatus = "verified"
+)
+
+type InstalledModel struct {
+	Name     string      `json:"name"`
+	Path     string      `json:"path"`
+	Size     int64       `json:"size"`
+	LastUsed time.Time   `json:"lastUsed"`
+	Status   ModelStatus `json:"status"`
+}
+
+type DiskUsage struct {
+	Total  int64            `json:"total"`
+	Models []InstalledModel `json:"models"`
+}
+
+var usageMu sync.Mutex
+
+func (w *Whisper) modelsDir() string {
+	return os.ExpandEnv(w.config.GetConfig().ModelPath)
+}
+
+func validateModelName(modelname string) error {
+	if modelname == "" || strings.ContainsAny(modelname, `/\`) || strings.Contains(modelname, "..") {
+		return fmt.Errorf("Invalid model name %q", modelname)
+	}
+
+	return nil
+}
+
+func (w *Whisper) ListInstalledModels() ([]InstalledModel, error) {
+	models := []InstalledModel{}
+	dir := w.modelsDir()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return models, nil
+		}
+
+		return models, fmt.Errorf("Couldn't read models dir: %w", err)
+	}
+
+	usage, err := readUsage(dir)
+	if err != nil {
+		fmt.Println("Error while reading models usage:", err)
+	}
+
+	for _, file := range files {
+		filename := file.Name()
+		if file.IsDir() || filepath.Ext(filename) != srcExt {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			fmt.Println("Error while getting model info:", err)
+			continue
+		}
+
+		name := strings.TrimSuffix(filename, srcExt)
+		modelPath := filepath.Join(dir, filename)
+
+		lastUsed, ok := usage[name]
+		if !ok {
+			lastUsed = info.ModTime()
+		}
+
+		models = append(models, InstalledModel{
+			Name:     name,
+			Path:     modelPath,
+			Size:     info.Size(),
+			LastUsed: lastUsed,
+			Status:   verifyModel(modelPath),
+		})
+	}
+
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+
+	return models, nil
+}
+
+func (w *Whisper) DeleteModel(modelname string) error {
+	if err := validateModelName(modelname); err != nil {
+		return err
+	}
+
+	if err := os.Remove(w.getModelPath(modelname)); err != nil {
+		return fmt.Errorf("Couldn't delete model %s: %w", modelname, err)
+	}
+
+	if err := updateUsage(w.modelsDir(), func(usage map[string]time.Time) {
+		delete(usage, modelname)
+	}); err != nil {
+		fmt.Println("Error while updating models usage:", err)
+	}
+
+	return nil
+}
+
+func (w *Whisper) GetDiskUsage() (DiskUsage, error) {
+	models, err := w.ListInstalledModels()
+	if err != nil {
+		return DiskUsage{Models: models}, err
+	}
+
+	usage := DiskUsage{Models: models}
+	for _, model := range models {
+		usage.Total += model.Size
+	}
+
+	return usage, nil
+}
+
+// Removes every installed model that wasn't loaded in the last `days` days,
+// except the currently selected one. Returns names of deleted models
+func (w *Whisper) CleanupUnusedModels(days int) ([]string, error) {
+	removed := []string{}
+
+	if days <= 0 {
+		return removed, fmt.Errorf("Days should be positive, got %d", days)
+	}
+
+	models, err := w.ListInstalledModels()
+	if err != nil {
+		return removed, err
+	}
+
+	current := w.config.GetConfig().CurrentModel
+	deadline := time.Now().AddDate(0, 0, -days)
+
+	for _, model := range models {
+		if model.Name == current || model.LastUsed.After(deadline) {
+			continue
+		}
+
+		if err := w.DeleteModel(model.Name); err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, model.Name)
+	}
+
+	return removed, nil
+}
+
+func verifyModel(modelPath string) ModelStatus {
+	file, err := os.Open(modelPath)
+	if err != nil {
+		return ModelStatusInvalid
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(ggmlMagic))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return ModelStatusInvalid
+	}
+
+	if !bytes.Equal(magic, ggmlMagic) {
+		return ModelStatusInvalid
+	}
+
+	return ModelStatusOk
+}
+
+func (w *Whisper) markModelUsed(modelname string) {
+	if err := updateUsage(w.modelsDir(), func(usage map[string]time.Time) {
+		usage[modelname] = time.Now()
+	}); err != nil {
+		fmt.Println("Error while updating models usage:", err)
+	}
+}
+
+func readUsage(dir string) (map[string]time.Time, error) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	return readUsageLocked(dir)
+}
+
+func readUsageLocked(dir string) (map[string]time.Time, error) {
+	usage := map[string]time.Time{}
+
+	bytes, err := os.ReadFile(filepath.Join(dir, usageFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return usage, nil
+		}
+
+		return usage, err
+	}
+
+	if err := json.Unmarshal(bytes, &usage); err != nil {
+		return map[string]time.Time{}, fmt.Errorf("Invalid usage file: %w", err)
+	}
+
+	return usage, nil
+}
+
+func updateUsage(dir string, update func(map[string]time.Time)) error {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	usage, err := readUsageLocked(dir)
+	if err != nil {
+		fmt.Println("Resetting models usage:", err)
+	}
+
+	update(usage)
+
+	bytes, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, usageFile), bytes, 0600)
+}
@@ -2,13 +2,24 @@ package whisper
 
 import (
 	"fmt"
+	goruntime "runtime"
 	"strings"
 
+	whisperLow "github.com/ggerganov/whisper.cpp/bindings/go"
 	whisperCpp "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+	"github.com/henmalib/whisper-notes/backend/transcript"
 )
 
-func (w *Whisper) loadModel(modelname string) (whisperCpp.Model, error) {
-	modelPath := w.getModelPath(modelname)
+// Model loaded once and used for language detection and every transcription
+// made with it. Detection needs mel spectrogram access, which high level
+// bindings don't expose, so it works on top of the raw whisper context
+type loadedModel struct {
+	name        string
+	ctx         *whisperLow.Context
+	tinydiarize bool
+}
+
+func (w *Whisper) loadModel(modelname string) (*loadedModel, error) {
 	isInstalled, e := w.IsModelInstalled(modelname)
 
 	if !isInstalled || e != nil {
@@ -16,15 +27,64 @@ func (w *Whisper) loadModel(modelname string) (whisperCpp.Model, error) {
 		return nil, fmt.Errorf("Model %s is not installed", modelname)
 	}
 
-	model, err := whisperCpp.New(modelPath)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to load whisper model %s: %w", modelname, err)
+	ctx := whisperLow.Whisper_init(w.getModelPath(modelname))
+	if ctx == nil {
+		return nil, fmt.Errorf("Unable to load whisper model %s: %w", modelname, whisperCpp.ErrUnableToLoadModel)
+	}
+
+	model := &loadedModel{name: modelname, ctx: ctx}
+	if entry, err := w.findModel(modelname); err == nil {
+		model.tinydiarize = entry.Tinydiarize
 	}
 
 	return model, nil
 }
 
-func (w *Whisper) Process(modelname string, data []float32, lang string, processCallback func(int)) (transcibedResult string, processErr error) {
+func (m *loadedModel) Close() {
+	m.ctx.Whisper_free()
+}
+
+func (m *loadedModel) isMultilingual() bool {
+	return m.ctx.Whisper_is_multilingual() != 0
+}
+
+// Same settings as NewContext of pkg/whisper
+func (m *loadedModel) defaultParams() whisperLow.Params {
+	params := m.ctx.Whisper_full_default_params(whisperLow.SAMPLING_GREEDY)
+	params.SetTranslate(false)
+	params.SetPrintSpecial(false)
+	params.SetPrintProgress(false)
+	params.SetPrintRealtime(false)
+	params.SetPrintTimestamps(false)
+	params.SetThreads(goruntime.NumCPU())
+	params.SetNoContext(true)
+	params.SetTemperature(0)
+	// Without it tokens only get timing of their segment
+	params.SetTokenTimestamps(true)
+
+	return params
+}
+
+func (w *Whisper) Process(modelname string, data []float32, lang string, processCallback func(int)) (*transcript.Transcript, error) {
+	result, err := w.process(modelname, data, lang, processCallback)
+	if err == nil {
+		w.markModelUsed(modelname)
+	}
+
+	return result, err
+}
+
+func (w *Whisper) process(modelname string, data []float32, lang string, processCallback func(int)) (*transcript.Transcript, error) {
+	model, err := w.loadModel(modelname)
+	if err != nil {
+		return nil, err
+	}
+	defer model.Close()
+
+	return model.process(data, lang, processCallback)
+}
+
+func (m *loadedModel) process(data []float32, lang string, processCallback func(int)) (transcibedResult *transcript.Transcript, processErr error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if e, ok := r.(error); ok {
@@ -35,36 +95,102 @@ func (w *Whisper) Process(modelname string, data []float32, lang string, process
 		}
 	}()
 
-	model, err := w.loadModel(modelname)
+	if m.tinydiarize {
+		return m.transcribeDiarized(data, processCallback)
+	}
+
+	// "auto" in whisper doesn't tell how sure it was, so detect it beforehand
+	var probability float32
+	if lang == "auto" {
+		probs, err := m.detectLanguage(data[:min(len(data), detectSeconds*whisperCpp.SampleRate)])
+		if err != nil {
+			fmt.Println("Couldn't detect language, leaving it to whisper:", err)
+		} else if len(probs) > 0 {
+			lang = probs[0].Language
+			probability = probs[0].Probability
+		}
+	}
 
+	result, err := m.transcribe(data, lang, processCallback)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	defer model.Close()
+	result.Model = m.name
+	result.LanguageProbability = probability
 
+	return result, nil
+}
+
+func (m *loadedModel) transcribe(data []float32, lang string, processCallback func(int)) (*transcript.Transcript, error) {
 	// WAV with 1 channel and model and SampleRate of whisperCpp.SampleRate
+	if len(data) == 0 {
+		return nil, fmt.Errorf("No audio data provided")
+	}
 
-	modelContext, err := model.NewContext()
-	if err != nil {
-		return "", fmt.Errorf("Unable to create model context: %w", err)
+	params := m.defaultParams()
+	params.SetBeamSize(3)
+	// A segment per decoded window, like the high level bindings do when
+	// segments are read through a callback
+	params.SetSingleSegment(true)
+
+	// English only models ignore the language
+	if m.isMultilingual() {
+		if lang == "auto" {
+			params.SetLanguage(-1)
+		} else if id := m.ctx.Whisper_lang_id(lang); id >= 0 {
+			params.SetLanguage(id)
+		}
+	}
+
+	if err := m.ctx.Whisper_full(params, data, nil, nil, func(progress int) {
+		if processCallback != nil {
+			processCallback(progress)
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("Unable to process audio file: %w", err)
 	}
 
-	modelContext.SetTranslate(false)
-	modelContext.SetLanguage(lang)
+	result := &transcript.Transcript{}
+	texts := []string{}
+	eot := m.ctx.Whisper_token_eot()
+
+	for i := range m.ctx.Whisper_full_n_segments() {
+		tokens := []transcript.Token{}
+		for j := range m.ctx.Whisper_full_n_tokens(i) {
+			// Everything starting from EOT are special tokens
+			if m.ctx.Whisper_full_get_token_id(i, j) >= eot {
+				continue
+			}
 
-	modelContext.SetBeamSize(3)
-	modelContext.SetTemperature(0)
+			data := m.ctx.Whisper_full_get_token_data(i, j)
+			tokens = append(tokens, transcript.Token{
+				Text:        m.ctx.Whisper_full_get_token_text(i, j),
+				Start:       data.T0() * 10,
+				End:         data.T1() * 10,
+				Probability: m.ctx.Whisper_full_get_token_p(i, j),
+			})
+		}
 
-	var sb strings.Builder
+		segment := transcript.Segment{
+			Start: m.ctx.Whisper_full_get_segment_t0(i) * 10,
+			End:   m.ctx.Whisper_full_get_segment_t1(i) * 10,
+			Text:  strings.TrimSpace(m.ctx.Whisper_full_get_segment_text(i)),
+			Words: transcript.GroupWords(tokens),
+		}
+		segment.FixWordTimes()
 
-	if err = modelContext.Process(data, nil, func(s whisperCpp.Segment) {
-		sb.WriteString(s.Text)
-	}, processCallback); err != nil {
-		return "", fmt.Errorf("Unable to process audio file: %w", err)
+		texts = append(texts, segment.Text)
+		result.Segments = append(result.Segments, segment)
 	}
 
-	return sb.String(), nil
+	result.Text = strings.Join(texts, " ")
+	result.Language = lang
+	if lang == "auto" {
+		result.Language = whisperLow.Whisper_lang_str(m.ctx.Whisper_full_lang_id())
+	}
+
+	return result, nil
 }
 
 func (w *Whisper) GetModelLanguages(modelname string) ([]string, error) {
@@ -76,5 +202,12 @@ func (w *Whisper) GetModelLanguages(modelname string) ([]string, error) {
 	}
 	defer model.Close()
 
-	return model.Languages(), nil
+	for id := range whisperLow.Whisper_lang_max_id() {
+		lang := whisperLow.Whisper_lang_str(id)
+		if model.ctx.Whisper_lang_id(lang) >= 0 {
+			langs = append(langs, lang)
+		}
+	}
+
+	return langs, nil
 }
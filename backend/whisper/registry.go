@@ -0,0 +1,164 @@
+package whisper
+
+import (
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const registryFile = "registry.json"
+
+//go:embed registry.json
+var defaultRegistry []byte
+
+type ModelEntry struct {
+	Name string `json:"name"`
+	// Remote file name, resolved against srcUrl unless Url is set
+	File string `json:"file"`
+	Url  string `json:"url,omitempty"`
+	// Approximate download size in bytes
+	Size         int64  `json:"size"`
+	Sha1         string `json:"sha1,omitempty"`
+	Multilingual bool   `json:"multilingual"`
+	Quantization string `json:"quantization,omitempty"`
+	// Approximate memory needed to run the model
+	RamMb int `json:"ramMb"`
+	// Empty means every language whisper supports
+	Languages []string `json:"languages,omitempty"`
+	// Model marks speaker turns, see https://github.com/akashmjn/tinydiarize
+	Tinydiarize bool `json:"tinydiarize,omitempty"`
+}
+
+type registry struct {
+	Models []ModelEntry `json:"models"`
+}
+
+func parseRegistry(bytes []byte) ([]ModelEntry, error) {
+	var reg registry
+	if err := json.Unmarshal(bytes, &reg); err != nil {
+		return nil, err
+	}
+
+	for i, entry := range reg.Models {
+		if err := validateModelName(entry.Name); err != nil {
+			return nil, err
+		}
+
+		if entry.File == "" {
+			reg.Models[i].File = "ggml-" + entry.Name + srcExt
+		}
+	}
+
+	return reg.Models, nil
+}
+
+func (w *Whisper) registryPath() string {
+	if p := w.config.GetConfig().ModelRegistryPath; p != "" {
+		return os.ExpandEnv(p)
+	}
+
+	return filepath.Join(w.modelsDir(), registryFile)
+}
+
+// Embedded registry with entries from the local registry file on top of it.
+// Local entries replace embedded ones with the same name
+func (w *Whisper) loadRegistry() ([]ModelEntry, error) {
+	models, err := parseRegistry(defaultRegistry)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid embedded model registry: %w", err)
+	}
+
+	bytes, err := os.ReadFile(w.registryPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return models, nil
+		}
+
+		return models, fmt.Errorf("Couldn't read model registry: %w", err)
+	}
+
+	local, err := parseRegistry(bytes)
+	if err != nil {
+		return models, fmt.Errorf("Invalid model registry %s: %w", w.registryPath(), err)
+	}
+
+	indexes := map[string]int{}
+	for i, entry := range models {
+		indexes[entry.Name] = i
+	}
+
+	for _, entry := range local {
+		if i, ok := indexes[entry.Name]; ok {
+			models[i] = entry
+			continue
+		}
+
+		indexes[entry.Name] = len(models)
+		models = append(models, entry)
+	}
+
+	return models, nil
+}
+
+func (w *Whisper) findModel(modelname string) (*ModelEntry, error) {
+	models, err := w.loadRegistry()
+	if err != nil {
+		fmt.Println("Error while loading model registry:", err)
+	}
+
+	for _, entry := range models {
+		if entry.Name == modelname {
+			return &entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Model %s is not in the registry", modelname)
+}
+
+func (e *ModelEntry) url() (string, error) {
+	if e.Url != "" {
+		return e.Url, nil
+	}
+
+	return urlForModel(e.File)
+}
+
+// Hashes the installed model and compares it against the registry checksum
+func (w *Whisper) VerifyModel(modelname string) (ModelStatus, error) {
+	if err := validateModelName(modelname); err != nil {
+		return ModelStatusInvalid, err
+	}
+
+	modelPath := w.getModelPath(modelname)
+	if status := verifyModel(modelPath); status != ModelStatusOk {
+		return status, nil
+	}
+
+	entry, err := w.findModel(modelname)
+	if err != nil || entry.Sha1 == "" {
+		return ModelStatusUnknown, nil
+	}
+
+	file, err := os.Open(modelPath)
+	if err != nil {
+		return ModelStatusInvalid, err
+	}
+	defer file.Close()
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ModelStatusInvalid, fmt.Errorf("Couldn't hash model %s: %w", modelname, err)
+	}
+
+	if hex.EncodeToString(hash.Sum(nil)) != entry.Sha1 {
+		return ModelStatusMismatch, nil
+	}
+
+	return ModelStatusVerified, nil
+}
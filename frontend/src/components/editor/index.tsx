@@ -1,4 +1,5 @@
 import { HistoryPlugin } from "@lexical/react/LexicalHistoryPlugin";
+import { OnChangePlugin } from "@lexical/react/LexicalOnChangePlugin";
 import { MarkdownShortcutPlugin } from "@lexical/react/LexicalMarkdownShortcutPlugin";
 import { HeadingNode, QuoteNode, RichTextExtension } from "@lexical/rich-text";
 import { ListNode, ListItemNode } from "@lexical/list";
@@ -52,7 +53,14 @@ const theme = {
   },
 };
 
-export const Editor = ({ text }: { text: string }) => {
+export const Editor = ({
+  text,
+  onChange,
+}: {
+  text: string;
+  // Called with the Markdown of the note after every edit
+  onChange?: (text: string) => void;
+}) => {
   const appExtension = defineExtension({
     name: "note-editor",
     theme,
@@ -96,6 +104,17 @@ export const Editor = ({ text }: { text: string }) => {
       <HistoryPlugin />
 
       <MarkdownShortcutPlugin transformers={TRANSFORMERS} />
+
+      {onChange && (
+        <OnChangePlugin
+          ignoreSelectionChange
+          onChange={(editorState) =>
+            editorState.read(() =>
+              onChange($convertToMarkdownString(TRANSFORMERS, undefined, true)),
+            )
+          }
+        />
+      )}
     </LexicalExtensionComposer>
   );
 };
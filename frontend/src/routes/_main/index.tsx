@@ -29,10 +29,12 @@ const formatDownloadString = (model: string, progress: number) => {
 
 const Audio = ({
   selectedLanguage,
+  selectedProfile,
   disabled,
 }: {
   currentModel: string;
   selectedLanguage: string;
+  selectedProfile: string;
   disabled: boolean;
 }) => {
   const [isRecording, setRecording] = useState(false);
@@ -65,6 +67,7 @@ const Audio = ({
       const noteId = await ProcessAndSaveNote(
         audio,
         selectedLanguage,
+        selectedProfile,
         toastId.toString(),
       );
 
@@ -121,13 +124,28 @@ const getLanguageForSelect = async (model: string) => {
 
 function App() {
   const {
-    config: { CurrentModel: model, PreferedLanguage },
+    config: {
+      CurrentModel: model,
+      PreferedLanguage,
+      Profiles: profiles,
+      DefaultProfile,
+      Engine,
+    },
     models,
   } = Route.useLoaderData();
 
   const [isDownloading, setDownloading] = useState(false);
   const [language, setCurrentLanguage] = useState(PreferedLanguage);
   const [selectedModel, setSelectedModel] = useState(model);
+  const [profileName, setProfileName] = useState(DefaultProfile);
+  const profile = (profiles || []).find(({ Name }) => Name === profileName);
+  // Remote profiles don't need a model downloaded
+  const isRemote = (profile?.Engine || Engine) === "remote";
+
+  const setProfile = (name: string) => {
+    UpdateConfig("DefaultProfile", name);
+    setProfileName(name);
+  };
 
   const setLanguage = (lang: string) => {
     UpdateConfig("PreferedLanguage", lang);
@@ -181,6 +199,23 @@ function App() {
 
   return (
     <div>
+      {profiles?.length > 0 && (
+        <Select onValueChange={setProfile} value={profile?.Name}>
+          <SelectTrigger className="w-[180px]">
+            <SelectValue placeholder="Select a profile" />
+          </SelectTrigger>
+          <SelectContent>
+            <SelectGroup>
+              {profiles.map(({ Name }) => (
+                <SelectItem key={Name} value={Name}>
+                  {Name}
+                </SelectItem>
+              ))}
+            </SelectGroup>
+          </SelectContent>
+        </Select>
+      )}
+
       <Select
         onValueChange={setModel}
         disabled={isDownloading}
@@ -191,9 +226,9 @@ function App() {
         </SelectTrigger>
         <SelectContent>
           <SelectGroup>
-            {models.map((model) => (
-              <SelectItem key={model} value={model}>
-                {model}
+            {models.map(({ name }) => (
+              <SelectItem key={name} value={name}>
+                {name}
               </SelectItem>
             ))}
           </SelectGroup>
@@ -221,9 +256,10 @@ function App() {
       </Button>
 
       <Audio
-        disabled={!isSelectedModelInstalled || !language}
+        disabled={(!isRemote && !isSelectedModelInstalled) || !language}
         currentModel={selectedModel}
-        selectedLanguage={language}
+        selectedLanguage={profile?.Language || language}
+        selectedProfile={profile?.Name ?? ""}
       />
     </div>
   );
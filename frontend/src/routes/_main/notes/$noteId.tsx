@@ -5,6 +5,20 @@ import {
   CollapsibleContent,
   CollapsibleTrigger,
 } from "@/components/ui/collapsible";
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from "@/components/ui/dialog";
+import {
+  DropdownMenu,
+  DropdownMenuContent,
+  DropdownMenuItem,
+  DropdownMenuTrigger,
+} from "@/components/ui/dropdown-menu";
 import {
   MediaPlayer,
   MediaPlayerAudio,
@@ -14,9 +28,11 @@ import {
   MediaPlayerSeek,
   MediaPlayerVolume,
 } from "@/components/ui/media-player";
+import { Textarea } from "@/components/ui/textarea";
 import { cn } from "@/lib/utils";
-import { createFileRoute } from "@tanstack/react-router";
+import { createFileRoute, useRouter } from "@tanstack/react-router";
 import {
+  ExportNotes,
   GetNoteMetadata,
   GetNoteAudios,
   GetNoteText,
@@ -24,8 +40,10 @@ import {
 } from "@wailsjs/go/fronthelpers/FrontHelpers";
 import { notes } from "@wailsjs/go/models";
 import { FindNote } from "@wailsjs/go/notes/Notes";
+import { EventsOn } from "@wailsjs/runtime/runtime.js";
 import { ChevronDown } from "lucide-react";
-import { useState } from "react";
+import { useEffect, useState } from "react";
+import { toast } from "sonner";
 
 export function AudioPlayer(audio: notes.AudioFile) {
   const [isOpen, setOpen] = useState(false);
@@ -91,15 +109,166 @@ export const Route = createFileRoute("/_main/notes/$noteId")({
   shouldReload: true,
 });
 
+// Save rejected by the backend because the note changed on disk since it was loaded
+type Conflict = {
+  noteId: string;
+  mine: notes.NoteSnapshot;
+  theirs: notes.NoteSnapshot;
+};
+
+const isConflict = (error: unknown): error is Conflict =>
+  typeof error === "object" &&
+  error !== null &&
+  "mine" in error &&
+  "theirs" in error;
+
+const ConflictDialog = ({
+  conflict,
+  onKeepTheirs,
+  onSave,
+}: {
+  conflict: Conflict | null;
+  onKeepTheirs: () => void;
+  onSave: (text: string) => void;
+}) => {
+  const [merged, setMerged] = useState("");
+
+  useEffect(() => {
+    setMerged(conflict?.mine.text ?? "");
+  }, [conflict]);
+
+  return (
+    <Dialog open={!!conflict} onOpenChange={(open) => !open && onKeepTheirs()}>
+      <DialogContent className="sm:max-w-4xl overflow-y-auto max-h-[80vh]">
+        <DialogHeader>
+          <DialogTitle>This note was changed outside of the app</DialogTitle>
+          <DialogDescription>
+            Keep the version on disk, or edit yours to merge both and save it.
+          </DialogDescription>
+        </DialogHeader>
+
+        <div className="grid grid-cols-2 gap-4">
+          <div className="flex flex-col gap-2">
+            <div className="font-bold">Theirs</div>
+            <div className="whitespace-pre-wrap text-sm border rounded-md p-2 min-h-48">
+              {conflict?.theirs.text}
+            </div>
+          </div>
+          <div className="flex flex-col gap-2">
+            <div className="font-bold">Mine</div>
+            <Textarea
+              className="min-h-48"
+              value={merged}
+              onChange={(e) => setMerged(e.target.value)}
+            />
+          </div>
+        </div>
+
+        <DialogFooter>
+          <Button variant="outline" onClick={onKeepTheirs}>
+            Keep theirs
+          </Button>
+          <Button onClick={() => onSave(merged)}>Save mine</Button>
+        </DialogFooter>
+      </DialogContent>
+    </Dialog>
+  );
+};
+
 function RouteComponent() {
   const { metadata, audios, text, note } = Route.useLoaderData();
   const [title, setTitle] = useState(metadata.title);
+  const [body, setBody] = useState(text);
+  const [version, setVersion] = useState(note.version);
+  const [conflict, setConflict] = useState<Conflict | null>(null);
+  // The editor only reads its text once, it's remounted with a new key to show another one
+  const [editorText, setEditorText] = useState({ text, key: 0 });
+  const router = useRouter();
+
+  // Reloaded after the note changed on disk
+  useEffect(() => {
+    setTitle(metadata.title);
+    setBody(text);
+    setVersion(note.version);
+    setEditorText((current) => ({ text, key: current.key + 1 }));
+  }, [note.version, metadata.title, text]);
+
+  useEffect(() => {
+    const offUpdated = EventsOn("notes:updated", (event) => {
+      if (event.noteId !== note.id || event.version === version) {
+        return;
+      }
+
+      if (title !== metadata.title || body !== text) {
+        // Recordings only refresh derived metadata, the text and title
+        // being edited stay the same and the new version can be kept
+        Promise.all([GetNoteText(note), GetNoteMetadata(note)])
+          .then(([current, currentMetadata]) => {
+            if (current === text && currentMetadata.title === metadata.title) {
+              setVersion(event.version);
+              return;
+            }
 
-  const save = async () => {
-    SaveNote(note.id, "test", {
-      ...metadata,
-      title,
+            toast.warning(
+              "This note was changed outside of the app, saving will show both versions",
+            );
+          })
+          .catch((e) => toast.error(`Couldn't read note: ${e}`));
+        return;
+      }
+
+      router.invalidate({ filter: (match) => match.routeId === Route.id });
+    });
+
+    const offDeleted = EventsOn("notes:deleted", (event) => {
+      if (event.noteId === note.id) {
+        toast.warning("This note was deleted outside of the app");
+      }
     });
+
+    return () => {
+      offUpdated();
+      offDeleted();
+    };
+  }, [note, version, title, metadata.title, body, text, router]);
+
+  const save = async (text: string, baseVersion: string) => {
+    try {
+      const saved = await SaveNote(
+        note.id,
+        text,
+        {
+          ...metadata,
+          title,
+        },
+        baseVersion,
+      );
+      if (conflict) {
+        setEditorText((current) => ({ text, key: current.key + 1 }));
+      }
+      setBody(text);
+      setVersion(saved);
+      setConflict(null);
+    } catch (e) {
+      if (isConflict(e)) {
+        setConflict(e);
+        return;
+      }
+
+      toast.error(`Couldn't save note: ${e}`);
+    }
+  };
+
+  const keepTheirs = () => {
+    setConflict(null);
+    router.invalidate({ filter: (match) => match.routeId === Route.id });
+  };
+
+  const exportNote = async (format: string) => {
+    const saved = await ExportNotes([note.id], format);
+    if (saved) {
+      toast.success(`Exported to ${saved}`);
+    }
   };
 
   return (
@@ -120,9 +289,36 @@ function RouteComponent() {
         ))}
       </div>
 
-      <Button onClick={save}>SAVE</Button>
+      <Button onClick={() => save(body, version)}>SAVE</Button>
+
+      <DropdownMenu>
+        <DropdownMenuTrigger asChild>
+          <Button variant="outline">EXPORT</Button>
+        </DropdownMenuTrigger>
+        <DropdownMenuContent>
+          <DropdownMenuItem onSelect={() => exportNote("zip")}>
+            ZIP with recordings
+          </DropdownMenuItem>
+          <DropdownMenuItem onSelect={() => exportNote("html")}>
+            Web page
+          </DropdownMenuItem>
+          <DropdownMenuItem onSelect={() => exportNote("markdown")}>
+            Markdown
+          </DropdownMenuItem>
+        </DropdownMenuContent>
+      </DropdownMenu>
+
+      <Editor
+        key={editorText.key}
+        text={editorText.text}
+        onChange={setBody}
+      />
 
-      <Editor text={text} />
+      <ConflictDialog
+        conflict={conflict}
+        onKeepTheirs={keepTheirs}
+        onSave={(merged) => save(merged, conflict?.theirs.version ?? "")}
+      />
     </div>
   );
 }
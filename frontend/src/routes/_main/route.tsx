@@ -1,10 +1,31 @@
 import { useQuery } from "@tanstack/react-query";
-import { createFileRoute, Link, Outlet } from "@tanstack/react-router";
-import { GetNoteMetadata } from "@wailsjs/go/fronthelpers/FrontHelpers";
-import { ListNotes } from "@wailsjs/go/notes/Notes";
+import {
+  createFileRoute,
+  Link,
+  Outlet,
+  useRouter,
+} from "@tanstack/react-router";
+import {
+  ChooseImportFolder,
+  GetNoteMetadata,
+} from "@wailsjs/go/fronthelpers/FrontHelpers";
+import { ImportMarkdown, ListNotes } from "@wailsjs/go/notes/Notes";
+import { notes as notesModels } from "@wailsjs/go/models";
 import { List, RowComponentProps, useDynamicRowHeight } from "react-window";
 import { Route as NoteRoute } from "./notes/$noteId";
 import { GetConfig } from "@wailsjs/go/config/ConfigHelper";
+import { EventsOn } from "@wailsjs/runtime/runtime.js";
+import { useEffect, useState } from "react";
+import { Button } from "@/components/ui/button";
+import {
+  Dialog,
+  DialogContent,
+  DialogDescription,
+  DialogFooter,
+  DialogHeader,
+  DialogTitle,
+} from "@/components/ui/dialog";
+import { toast } from "sonner";
 
 export const Route = createFileRoute("/_main")({
   component: RouteComponent,
@@ -58,29 +79,132 @@ const Note = ({
   );
 };
 
+// Picks a folder, shows what a dry run would import and imports it once confirmed
+const ImportNotes = () => {
+  const [report, setReport] = useState<notesModels.ImportReport | null>(null);
+  const [importing, setImporting] = useState(false);
+
+  const choose = async () => {
+    const folder = await ChooseImportFolder();
+    if (folder) {
+      setReport(await ImportMarkdown(folder, true));
+    }
+  };
+
+  const confirm = async () => {
+    if (!report) {
+      return;
+    }
+
+    setImporting(true);
+    try {
+      const imported = await ImportMarkdown(report.source, false);
+      toast.success(`Imported ${imported.notes.length} notes`);
+      imported.errors.forEach((error) => toast.error(error));
+      setReport(null);
+    } finally {
+      setImporting(false);
+    }
+  };
+
+  const attachments = report?.notes.reduce(
+    (count, note) => count + note.attachments.length,
+    0,
+  );
+  const unresolved = report?.notes.filter((note) => note.unresolved.length);
+
+  return (
+    <>
+      <Button variant="ghost" className="w-full rounded-none" onClick={choose}>
+        IMPORT
+      </Button>
+
+      <Dialog open={!!report} onOpenChange={(open) => !open && setReport(null)}>
+        <DialogContent className="overflow-y-auto max-h-[80vh]">
+          <DialogHeader>
+            <DialogTitle>Import from {report?.source}</DialogTitle>
+            <DialogDescription>
+              {report?.notes.length} notes with {attachments} attachments will
+              be imported, {report?.skipped.length} other files are skipped.
+            </DialogDescription>
+          </DialogHeader>
+
+          {!!unresolved?.length && (
+            <div className="text-sm">
+              <div className="font-bold">Links that point to nothing</div>
+              {unresolved.map((note) => (
+                <div key={note.path}>
+                  {note.path}: {note.unresolved.join(", ")}
+                </div>
+              ))}
+            </div>
+          )}
+
+          {report?.errors.map((error) => (
+            <div key={error} className="text-sm text-destructive">
+              {error}
+            </div>
+          ))}
+
+          <DialogFooter>
+            <Button variant="outline" onClick={() => setReport(null)}>
+              Cancel
+            </Button>
+            <Button
+              disabled={importing || !report?.notes.length}
+              onClick={confirm}
+            >
+              Import
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+    </>
+  );
+};
+
 const NotesList = () => {
   const { notes } = Route.useLoaderData();
+  const router = useRouter();
+
+  // Notes changed on disk, by the app or e.g. a sync tool
+  useEffect(() => {
+    const reload = () =>
+      router.invalidate({ filter: (match) => match.routeId === Route.id });
+
+    const unsubscribe = [
+      EventsOn("notes:created", reload),
+      EventsOn("notes:updated", reload),
+      EventsOn("notes:deleted", reload),
+    ];
+
+    return () => unsubscribe.forEach((off) => off());
+  }, [router]);
 
   const rowHeight = useDynamicRowHeight({
     defaultRowHeight: 66,
   });
 
   return (
-    <div className="w-60 h-full overflow-hidden border border-border relative min-h-0">
-      <div className="absolute inset-0">
-        <List
-          style={{
-            width: "100%",
-            height: "100%",
-          }}
-          className="bg-muted/60 divide-border divide-y-2 h-full w-full overscroll-none"
-          rowComponent={Note}
-          rowCount={notes.length}
-          rowHeight={rowHeight}
-          overscanCount={5}
-          defaultHeight={1100}
-          rowProps={{ notes }}
-        />
+    <div className="w-60 h-full overflow-hidden border border-border relative min-h-0 flex flex-col">
+      <ImportNotes />
+
+      <div className="relative flex-1 min-h-0">
+        <div className="absolute inset-0">
+          <List
+            style={{
+              width: "100%",
+              height: "100%",
+            }}
+            className="bg-muted/60 divide-border divide-y-2 h-full w-full overscroll-none"
+            rowComponent={Note}
+            rowCount={notes.length}
+            rowHeight={rowHeight}
+            overscanCount={5}
+            defaultHeight={1100}
+            rowProps={{ notes }}
+          />
+        </div>
       </div>
     </div>
   );
@@ -1,4 +1,4 @@
-import { createFileRoute } from "@tanstack/react-router";
+import { createFileRoute, useRouter } from "@tanstack/react-router";
 import licenses from "../assets/licenses.json";
 import {
   Table,
@@ -22,6 +22,8 @@ import {
 } from "@/components/ui/collapsible";
 import { ChevronDown } from "lucide-react";
 import { GetAudioDevices } from "@wailsjs/go/audio/Audio";
+import { ConvertLayout } from "@wailsjs/go/notes/Notes";
+import { toast } from "sonner";
 
 export const Route = createFileRoute("/settings")({
   component: SettingsPage,
@@ -35,12 +37,15 @@ export const Route = createFileRoute("/settings")({
       deviceId = devices.find((d) => d.isDefault)?.deviceId || "";
     }
 
-    return devices.map((d) => {
-      return {
-        ...d,
-        selected: d.deviceId === deviceId,
-      };
-    });
+    return {
+      devices: devices.map((d) => {
+        return {
+          ...d,
+          selected: d.deviceId === deviceId,
+        };
+      }),
+      notesLayout: config.NotesLayout || "folders",
+    };
   },
 });
 
@@ -58,8 +63,49 @@ const LicenseTextDialog = ({
   );
 };
 
+const layouts = [
+  { value: "folders", label: "A folder per note" },
+  { value: "vault", label: "Obsidian vault: Markdown files named by title" },
+];
+
+const NotesLayout = ({ current }: { current: string }) => {
+  const router = useRouter();
+  const [converting, setConverting] = React.useState(false);
+
+  const convert = async (layout: string) => {
+    setConverting(true);
+    try {
+      await ConvertLayout(layout);
+      toast.success("Notes moved to the new layout");
+    } catch (e) {
+      toast.error(`Couldn't convert notes: ${e}`);
+    } finally {
+      setConverting(false);
+      router.invalidate();
+    }
+  };
+
+  return (
+    <div className="flex flex-col gap-2 mt-8">
+      <div>Notes layout</div>
+      <RadioGroup
+        disabled={converting}
+        onValueChange={convert}
+        value={current}
+      >
+        {layouts.map((layout) => (
+          <div key={layout.value} className="flex flex-row gap-2">
+            <RadioGroupItem value={layout.value} id={`layout-${layout.value}`} />
+            <Label htmlFor={`layout-${layout.value}`}>{layout.label}</Label>
+          </div>
+        ))}
+      </RadioGroup>
+    </div>
+  );
+};
+
 function SettingsPage() {
-  const devices = Route.useLoaderData();
+  const { devices, notesLayout } = Route.useLoaderData();
 
   return (
     <div className="h-full flex flex-col p-4">
@@ -77,6 +123,8 @@ function SettingsPage() {
         </RadioGroup>
       </div>
 
+      <NotesLayout current={notesLayout} />
+
       <Collapsible className="w-full mt-16 data-[state=open]:flex-1 data-[state=open]:flex data-[state=open]:flex-col data-[state=open]:min-h-0">
         <CollapsibleTrigger className="w-full">
           <div className="flex flex-row items-center justify-between p-2 px-4">
@@ -2,10 +2,12 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"os"
 	"runtime"
 
 	"github.com/henmalib/whisper-notes/backend/config"
+	"github.com/henmalib/whisper-notes/backend/notes"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -32,6 +34,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		Bind: []any{
 			app,
 			&app.Whisper,
@@ -42,6 +45,15 @@ func main() {
 				Appname: "notes",
 			},
 		},
+		// Conflicts carry both versions of the note, so the frontend can merge them
+		ErrorFormatter: func(err error) any {
+			var conflict *notes.ConflictError
+			if errors.As(err, &conflict) {
+				return conflict
+			}
+
+			return err.Error()
+		},
 		Mac: &mac.Options{
 			WebviewIsTransparent: true,
 		},